@@ -0,0 +1,245 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var tempSeq uint32
+
+// createTemp creates a new temporary file in the directory of filePath, so it can later be renamed over filePath.
+func (f *FS) createTemp(filePath string, perm os.FileMode) (File, error) {
+	dir, base := filepath.Split(filePath)
+	for i := 0; ; i++ {
+		suffix := strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatUint(uint64(atomic.AddUint32(&tempSeq, 1)), 36)
+		file, err := f.fs.OpenFile(filepath.Join(dir, "."+base+"."+suffix+".tmp"), os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && i < 10000 {
+			continue
+		}
+		return file, err
+	}
+}
+
+// writeAtomic replaces content of filePath with what write writes, so readers never see a partial file:
+// content is written and synced to a temporary file next to filePath, which is then renamed over it.
+// An existing file keeps its permission bits, a new one is created with 0644.
+func (f *FS) writeAtomic(filePath string, write func(w io.Writer) error) error {
+	perm := os.FileMode(0644)
+	if info, err := f.fs.Stat(filePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmpFile, err := f.createTemp(filePath, perm)
+	if err != nil {
+		return err
+	}
+
+	if err = write(tmpFile); err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = f.fs.Rename(tmpFile.Name(), filePath)
+	}
+	if err != nil {
+		f.fs.Remove(tmpFile.Name())
+	}
+	return err
+}
+
+// writeAtomicString is like writeAtomic for string data.
+func (f *FS) writeAtomicString(filePath string, data string) error {
+	return f.writeAtomic(filePath, func(w io.Writer) error {
+		_, err := io.WriteString(w, data)
+		return err
+	})
+}
+
+// WriteAll writes several files as a unit: every file is first written to a temporary file next to it,
+// and only once all of them succeeded are they renamed into place.
+// On a write failure, the temporary files are removed and no target is touched.
+// Renames themselves are not transactional, a failing rename leaves earlier targets replaced.
+func WriteAll(files map[string]string) error {
+	return DefaultFS.WriteAll(files)
+}
+
+// WriteAll is like the package-level WriteAll but operates on f.
+func (f *FS) WriteAll(files map[string]string) (err error) {
+	filePaths := make([]string, 0, len(files))
+	for filePath := range files {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	tmpPaths := make([]string, 0, len(filePaths))
+	defer func() {
+		if err != nil {
+			for _, tmpPath := range tmpPaths {
+				f.fs.Remove(tmpPath)
+			}
+		}
+	}()
+
+	for _, filePath := range filePaths {
+		perm := os.FileMode(0644)
+		if info, err := f.fs.Stat(filePath); err == nil {
+			perm = info.Mode().Perm()
+		}
+		tmpFile, err := f.createTemp(filePath, perm)
+		if err != nil {
+			return err
+		}
+		tmpPaths = append(tmpPaths, tmpFile.Name())
+
+		if _, err = io.WriteString(tmpFile, files[filePath]); err == nil {
+			err = tmpFile.Sync()
+		}
+		if closeErr := tmpFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, filePath := range filePaths {
+		if err = f.fs.Rename(tmpPaths[i], filePath); err != nil {
+			tmpPaths = tmpPaths[i:]
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMulti writes the same string data atomically into every given file.
+// A failure on one file doesn't prevent writing the others; all failures are returned in a MultiError.
+func WriteMulti(data string, filePaths ...string) error {
+	return DefaultFS.WriteMulti(data, filePaths...)
+}
+
+// WriteMulti is like the package-level WriteMulti but operates on f.
+func (f *FS) WriteMulti(data string, filePaths ...string) error {
+	var errs MultiError
+	for _, filePath := range filePaths {
+		if err := f.writeAtomicString(filePath, data); err != nil {
+			errs = append(errs, fmt.Errorf("write %s: %w", filePath, err))
+		}
+	}
+	return errs.errOrNil()
+}
+
+// ErrVerifyFailed is returned by WriteVerify, wrapped in a *os.PathError,
+// when the content read back after writing differs from what was written.
+var ErrVerifyFailed = errors.New("content read back differs from written data")
+
+// WriteVerify writes string data into file atomically, then reads the file back and compares it with data.
+// On a mismatch the file is removed, so a silently corrupted write doesn't stay around.
+func WriteVerify(filePath string, data string) error {
+	return DefaultFS.WriteVerify(filePath, data)
+}
+
+// WriteVerify is like the package-level WriteVerify but operates on f.
+func (f *FS) WriteVerify(filePath string, data string) error {
+	if err := f.writeAtomicString(filePath, data); err != nil {
+		return err
+	}
+	same, err := f.hasContent(filePath, data)
+	if err != nil {
+		return err
+	}
+	if !same {
+		f.fs.Remove(filePath)
+		return &os.PathError{Op: "verify", Path: filePath, Err: ErrVerifyFailed}
+	}
+	return nil
+}
+
+// CopyToMany copies file srcFilePath to every one of dstFilePaths, reading the source only once.
+// Each destination is written atomically. A failure on one destination doesn't prevent copying to the others;
+// all failures are returned in a MultiError.
+func CopyToMany(srcFilePath string, dstFilePaths []string) error {
+	return DefaultFS.CopyToMany(srcFilePath, dstFilePaths)
+}
+
+// CopyToMany is like the package-level CopyToMany but operates on f.
+func (f *FS) CopyToMany(srcFilePath string, dstFilePaths []string) (err error) {
+	srcFile, err := f.fs.Open(srcFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := srcFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	var errs MultiError
+	fw := &fanoutWriter{}
+	for _, dstFilePath := range dstFilePaths {
+		perm := os.FileMode(0644)
+		if info, err := f.fs.Stat(dstFilePath); err == nil {
+			perm = info.Mode().Perm()
+		}
+		tmpFile, err := f.createTemp(dstFilePath, perm)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("copy to %s: %w", dstFilePath, err))
+			continue
+		}
+		fw.targets = append(fw.targets, &fanoutTarget{path: dstFilePath, file: tmpFile})
+	}
+
+	_, copyErr := io.Copy(fw, srcFile)
+	for _, t := range fw.targets {
+		if t.err == nil {
+			t.err = copyErr
+		}
+		if t.err == nil {
+			t.err = t.file.Sync()
+		}
+		if closeErr := t.file.Close(); closeErr != nil && t.err == nil {
+			t.err = closeErr
+		}
+		if t.err == nil {
+			t.err = f.fs.Rename(t.file.Name(), t.path)
+		}
+		if t.err != nil {
+			f.fs.Remove(t.file.Name())
+			errs = append(errs, fmt.Errorf("copy to %s: %w", t.path, t.err))
+		}
+	}
+	return errs.errOrNil()
+}
+
+// fanoutTarget is a destination of a fanoutWriter.
+type fanoutTarget struct {
+	path string
+	file File
+	err  error
+}
+
+// fanoutWriter writes to all its targets. Unlike io.MultiWriter, a failing target is dropped
+// while writing goes on to the others.
+type fanoutWriter struct {
+	targets []*fanoutTarget
+}
+
+func (fw *fanoutWriter) Write(p []byte) (int, error) {
+	for _, t := range fw.targets {
+		if t.err != nil {
+			continue
+		}
+		if _, err := t.file.Write(p); err != nil {
+			t.err = err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,170 @@
+package file
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteAllFailureTouchesNothing(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.go")
+	writeTestFile(t, existing, "old")
+
+	// A missing directory can't hold the temporary file, failing that target like an unwritable one would,
+	// also when the tests run as root.
+	err := WriteAll(map[string]string{
+		existing:                              "new",
+		filepath.Join(dir, "created.go"):      "new",
+		filepath.Join(dir, "missing", "x.go"): "new",
+	})
+	if err == nil {
+		t.Fatal("WriteAll with an unwritable target succeeded")
+	}
+
+	if got := readTestFile(t, existing); got != "old" {
+		t.Errorf("existing target = %q, want it untouched", got)
+	}
+	names, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Errorf("directory holds %d entries, want only the existing target", len(names))
+	}
+}
+
+func TestWriteAll(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		filepath.Join(dir, "a.go"): "package a",
+		filepath.Join(dir, "b.go"): "package b",
+	}
+	if err := WriteAll(files); err != nil {
+		t.Fatal(err)
+	}
+	for filePath, want := range files {
+		if got := readTestFile(t, filePath); got != want {
+			t.Errorf("%s = %q, want %q", filePath, got, want)
+		}
+	}
+}
+
+func TestWriteMulti(t *testing.T) {
+	dir := t.TempDir()
+	ok1, ok2 := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	failing := filepath.Join(dir, "missing", "c.log")
+
+	err := WriteMulti("record\n", ok1, failing, ok2)
+	if err == nil {
+		t.Fatal("WriteMulti with a missing directory succeeded")
+	}
+	if !strings.Contains(err.Error(), failing) {
+		t.Errorf("error %q doesn't name the failing path %s", err, failing)
+	}
+	var multiErr MultiError
+	if !errors.As(err, &multiErr) || len(multiErr) != 1 {
+		t.Errorf("got %v, want a MultiError with one error", err)
+	}
+	for _, filePath := range []string{ok1, ok2} {
+		if got := readTestFile(t, filePath); got != "record\n" {
+			t.Errorf("%s = %q, want %q", filePath, got, "record\n")
+		}
+	}
+}
+
+func TestCopyToMany(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath := filepath.Join(dir, "src")
+	data := strings.Repeat("fan out\n", 10000)
+	writeTestFile(t, srcFilePath, data)
+	dstFilePaths := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")}
+	writeTestFile(t, dstFilePaths[1], "old")
+	if err := os.Chmod(dstFilePaths[1], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CopyToMany(srcFilePath, dstFilePaths); err != nil {
+		t.Fatal(err)
+	}
+	for _, dstFilePath := range dstFilePaths {
+		if got := readTestFile(t, dstFilePath); got != data {
+			t.Errorf("%s: got %d bytes, want %d", dstFilePath, len(got), len(data))
+		}
+	}
+	if info, err := os.Stat(dstFilePaths[1]); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("existing destination lost its mode: %v, %v", info, err)
+	}
+
+	missing := filepath.Join(dir, "missing", "d")
+	err := CopyToMany(srcFilePath, []string{missing, dstFilePaths[0]})
+	var errs MultiError
+	if !errors.As(err, &errs) || len(errs) != 1 || !strings.Contains(errs[0].Error(), missing) {
+		t.Errorf("CopyToMany with an unwritable destination: got %v, want a MultiError naming %s", err, missing)
+	}
+	if got := readTestFile(t, dstFilePaths[0]); got != data {
+		t.Errorf("failure on one destination prevented copying to the others")
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 4 {
+		t.Errorf("directory holds %d entries after copies, want 4 without temporary files", len(entries))
+	}
+}
+
+// corruptingFS is a memFS flipping the first byte of every write, like a faulty disk.
+type corruptingFS struct {
+	*memFS
+}
+
+func (c corruptingFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	file, err := c.memFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return corruptingFile{file}, nil
+}
+
+type corruptingFile struct {
+	File
+}
+
+func (f corruptingFile) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	corrupted := append([]byte(nil), p...)
+	corrupted[0] ^= 0xff
+	return f.File.Write(corrupted)
+}
+
+func TestWriteVerify(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data")
+	if err := WriteVerify(filePath, "payload"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filePath); got != "payload" {
+		t.Errorf("content = %q, want %q", got, "payload")
+	}
+
+	mem := newMemFS()
+	fs := NewFS(corruptingFS{mem})
+	err := fs.WriteVerify("/data", "payload")
+	if !errors.Is(err, ErrVerifyFailed) {
+		t.Fatalf("WriteVerify on a corrupting filesystem: got %v, want ErrVerifyFailed", err)
+	}
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "/data" {
+		t.Errorf("WriteVerify error %v doesn't carry the path", err)
+	}
+	if _, err := mem.Stat("/data"); !os.IsNotExist(err) {
+		t.Errorf("corrupted file left in place: %v", err)
+	}
+	mem.mu.Lock()
+	names := mem.children("/")
+	mem.mu.Unlock()
+	if len(names) != 0 {
+		t.Errorf("WriteVerify left %v behind", names)
+	}
+}
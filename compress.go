@@ -0,0 +1,53 @@
+package file
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// NewReader opens a file for reading, transparently decompressing it based on its extension:
+// ".gz" files are read through gzip and ".bz2" files through bzip2, other files are read as is.
+// Closing the returned reader closes both the decompressor and the file.
+func NewReader(filePath string) (io.ReadCloser, error) {
+	return DefaultFS.NewReader(filePath)
+}
+
+// NewReader is like the package-level NewReader but operates on f.
+func (f *FS) NewReader(filePath string) (io.ReadCloser, error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".gz":
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &decompressReader{Reader: gr, closers: []io.Closer{gr, file}}, nil
+	case ".bz2":
+		return &decompressReader{Reader: bzip2.NewReader(file), closers: []io.Closer{file}}, nil
+	default:
+		return file, nil
+	}
+}
+
+// decompressReader reads from a decompressor and closes it along with the underlying file.
+type decompressReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *decompressReader) Close() (err error) {
+	for _, c := range r.closers {
+		if closeErr := c.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
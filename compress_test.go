@@ -0,0 +1,62 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewReader(t *testing.T) {
+	dir := t.TempDir()
+	const want = "one\ntwo\n"
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(want))
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// bzip2 compressed "one\ntwo\n", the standard library only has a bzip2 decoder.
+	bz, err := hex.DecodeString("425a6839314159265359a7142b77000002c180001002018480200021800c0238f51b8bb9229c2848538a15bb80")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{
+		"app.log":     []byte(want),
+		"app.log.gz":  gz.Bytes(),
+		"app.log.GZ":  gz.Bytes(),
+		"app.log.bz2": bz,
+	} {
+		filePath := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewReader(filePath)
+		if err != nil {
+			t.Fatalf("NewReader(%s): %v", name, err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("reading %s: %v", name, err)
+		}
+		if err := r.Close(); err != nil {
+			t.Errorf("closing %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s read %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNewReaderInvalidGzip(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "broken.gz")
+	writeTestFile(t, filePath, "not gzip")
+	if _, err := NewReader(filePath); err == nil {
+		t.Error("NewReader of invalid gzip file succeeded")
+	}
+}
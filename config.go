@@ -0,0 +1,262 @@
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadEnvFile parses a .env style file of KEY=VALUE lines into a map.
+// Blank lines and lines starting with # are ignored, and an optional "export " prefix is allowed.
+// Keys and unquoted values are trimmed, and an unquoted value ends at a " #" comment.
+// Double-quoted values support Go escape sequences, single-quoted values are taken literally,
+// and a quoted value may be followed by a # comment.
+// When a key is repeated, the last value wins. A line without "=" or with an empty key is an error.
+func ReadEnvFile(filePath string) (map[string]string, error) {
+	return DefaultFS.ReadEnvFile(filePath)
+}
+
+// ReadEnvFile is like the package-level ReadEnvFile but operates on f.
+func (f *FS) ReadEnvFile(filePath string) (env map[string]string, err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	env = make(map[string]string)
+	lineNum := 0
+	err = forEachLine(file, func(line string) error {
+		lineNum++
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			return nil
+		}
+
+		key, value, err := parseEnvLine(strings.TrimPrefix(line, "export "))
+		if err != nil {
+			return &ParseError{Path: filePath, Err: fmt.Errorf("line %d: %w", lineNum, err)}
+		}
+		env[key] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+func parseEnvLine(line string) (key string, value string, err error) {
+	i := strings.IndexByte(line, '=')
+	if i < 0 {
+		return "", "", fmt.Errorf("missing '=' in %q", line)
+	}
+	if key = strings.TrimSpace(line[:i]); key == "" {
+		return "", "", fmt.Errorf("empty key in %q", line)
+	}
+
+	value = strings.TrimSpace(line[i+1:])
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		if j := strings.Index(value, " #"); j >= 0 {
+			value = strings.TrimSpace(value[:j])
+		}
+		return key, value, nil
+	}
+
+	end := closingQuote(value)
+	if end < 0 {
+		return "", "", fmt.Errorf("unterminated quoted value for %s", key)
+	}
+	if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", "", fmt.Errorf("unexpected %q after quoted value for %s", rest, key)
+	}
+	if value[0] == '\'' {
+		return key, value[1:end], nil
+	}
+	if value, err = strconv.Unquote(value[:end+1]); err != nil {
+		return "", "", fmt.Errorf("invalid quoted value for %s: %w", key, err)
+	}
+	return key, value, nil
+}
+
+// closingQuote returns the index of the quote closing the value starting with a quote, or -1.
+// Within double quotes, a quote escaped with a backslash doesn't close the value.
+func closingQuote(value string) int {
+	quote := value[0]
+	for i := 1; i < len(value); i++ {
+		switch {
+		case value[i] == quote:
+			return i
+		case value[i] == '\\' && quote == '"':
+			i++
+		}
+	}
+	return -1
+}
+
+// ReadYAML reads given YAML file and decodes it into v.
+// An error decoding the content is returned as a *ParseError.
+func ReadYAML(filePath string, v interface{}) error {
+	return DefaultFS.ReadYAML(filePath, v)
+}
+
+// ReadYAML is like the package-level ReadYAML but operates on f.
+func (f *FS) ReadYAML(filePath string, v interface{}) (err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(data, v); err != nil {
+		return &ParseError{Path: filePath, Err: err}
+	}
+	return nil
+}
+
+// WriteYAML encodes v as YAML and writes it atomically into given file.
+func WriteYAML(filePath string, v interface{}) error {
+	return DefaultFS.WriteYAML(filePath, v)
+}
+
+// WriteYAML is like the package-level WriteYAML but operates on f.
+func (f *FS) WriteYAML(filePath string, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return f.writeAtomicString(filePath, string(data))
+}
+
+// ReadINI parses an INI style file into a map of sections, each a map of keys to values.
+// Sections start with a [name] header, and hold the following key = value lines.
+// Keys appearing before any header belong to the section named "".
+// Blank lines and lines starting with ; or # are ignored, keys and values are trimmed,
+// and when a key is repeated within a section, the last value wins.
+// A line that is neither a header nor contains "=", or has an empty key, is an error.
+func ReadINI(filePath string) (map[string]map[string]string, error) {
+	return DefaultFS.ReadINI(filePath)
+}
+
+// ReadINI is like the package-level ReadINI but operates on f.
+func (f *FS) ReadINI(filePath string) (data map[string]map[string]string, err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	data = make(map[string]map[string]string)
+	section := ""
+	lineNum := 0
+	err = forEachLine(file, func(line string) error {
+		lineNum++
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			return nil
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return &ParseError{Path: filePath, Err: fmt.Errorf("line %d: unterminated section header %q", lineNum, line)}
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if data[section] == nil {
+				data[section] = make(map[string]string)
+			}
+			return nil
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return &ParseError{Path: filePath, Err: fmt.Errorf("line %d: missing '=' in %q", lineNum, line)}
+		}
+		key := strings.TrimSpace(line[:i])
+		if key == "" {
+			return &ParseError{Path: filePath, Err: fmt.Errorf("line %d: empty key in %q", lineNum, line)}
+		}
+		if data[section] == nil {
+			data[section] = make(map[string]string)
+		}
+		data[section][key] = strings.TrimSpace(line[i+1:])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// WriteINI writes data atomically into given file in the INI format read by ReadINI.
+// Sections and the keys within them are sorted, so the same data always gives the same file.
+// Keys of the section named "" come first, before any header, and that section is omitted when empty.
+// Anything ReadINI wouldn't read back unchanged is an error: a section, key or value containing a line break
+// or starting or ending with whitespace, a section containing "]", and a key that is empty, contains "="
+// or starts like a header or comment would.
+func WriteINI(filePath string, data map[string]map[string]string) error {
+	return DefaultFS.WriteINI(filePath, data)
+}
+
+// WriteINI is like the package-level WriteINI but operates on f.
+func (f *FS) WriteINI(filePath string, data map[string]map[string]string) error {
+	sections := make([]string, 0, len(data))
+	for section := range data {
+		if strings.ContainsAny(section, "\r\n]") || hasOuterSpace(section) {
+			return fmt.Errorf("invalid INI section name %q", section)
+		}
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+
+	var sb strings.Builder
+	for _, section := range sections {
+		keys := make([]string, 0, len(data[section]))
+		for key, value := range data[section] {
+			if key == "" || strings.ContainsAny(key, "=\r\n") || strings.ContainsAny(key[:1], "[;#") ||
+				hasOuterSpace(key) || strings.ContainsAny(value, "\r\n") || hasOuterSpace(value) {
+				return fmt.Errorf("invalid INI entry %q = %q in section %q", key, value, section)
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		if section != "" {
+			if sb.Len() > 0 {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "[%s]\n", section)
+		}
+		for _, key := range keys {
+			fmt.Fprintf(&sb, "%s = %s\n", key, data[section][key])
+		}
+	}
+	return f.writeAtomicString(filePath, sb.String())
+}
+
+// hasOuterSpace reports whether s starts or ends with whitespace, which ReadINI trims.
+func hasOuterSpace(s string) bool {
+	return strings.TrimSpace(s) != s
+}
@@ -0,0 +1,219 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), ".env")
+	writeTestFile(t, filePath, `# database settings
+DB_HOST = localhost
+DB_PORT=5432 # default port
+export DB_USER=admin
+
+GREETING="hello world" # greeting
+ESCAPED="line\nbreak \"quoted\""
+HASH="a # in quotes"
+LITERAL='x\n' # c
+EMPTY=
+URL=http://example.com/#anchor
+DB_HOST=db.internal
+`)
+
+	env, err := ReadEnvFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DB_HOST":  "db.internal",
+		"DB_PORT":  "5432",
+		"DB_USER":  "admin",
+		"GREETING": "hello world",
+		"ESCAPED":  "line\nbreak \"quoted\"",
+		"HASH":     "a # in quotes",
+		"LITERAL":  `x\n`,
+		"EMPTY":    "",
+		"URL":      "http://example.com/#anchor",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("ReadEnvFile = %v, want %v", env, want)
+	}
+}
+
+func TestReadEnvFileMalformed(t *testing.T) {
+	dir := t.TempDir()
+	for name, line := range map[string]string{
+		"missing equals":      "JUST_A_KEY",
+		"empty key":           "=value",
+		"unterminated":        `KEY="open`,
+		"after quoted":        `KEY="a" b`,
+		"invalid escape":      `KEY="\q"`,
+		"unterminated single": "KEY='open",
+	} {
+		filePath := filepath.Join(dir, name)
+		writeTestFile(t, filePath, "OK=1\n"+line+"\n")
+
+		_, err := ReadEnvFile(filePath)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: got %v, want a *ParseError", name, err)
+		}
+	}
+}
+
+type yamlConfig struct {
+	Name     string            `yaml:"name"`
+	Servers  []yamlServer      `yaml:"servers"`
+	Labels   map[string]string `yaml:"labels"`
+	Disabled bool              `yaml:"disabled"`
+}
+
+type yamlServer struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.yaml")
+	want := yamlConfig{
+		Name:    "app",
+		Servers: []yamlServer{{Host: "a.example", Port: 80}, {Host: "b.example", Port: 8080}},
+		Labels:  map[string]string{"env": "prod"},
+	}
+	if err := WriteYAML(filePath, want); err != nil {
+		t.Fatal(err)
+	}
+
+	var got yamlConfig
+	if err := ReadYAML(filePath, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadYAML = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadYAMLErrors(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "invalid.yaml")
+	writeTestFile(t, filePath, "name: [unclosed\n")
+
+	var v yamlConfig
+	err := ReadYAML(filePath, &v)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Path != filePath {
+		t.Errorf("ReadYAML of invalid YAML: got %v, want a *ParseError for %s", err, filePath)
+	}
+
+	err = ReadYAML(filepath.Join(dir, "missing.yaml"), &v)
+	if !os.IsNotExist(err) || errors.As(err, &parseErr) {
+		t.Errorf("ReadYAML of missing file: got %v, want a not-exist I/O error", err)
+	}
+}
+
+func TestReadINI(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "app.ini")
+	writeTestFile(t, filePath, `; global settings
+name = demo
+debug=true
+
+[server]
+# listen address
+host = 0.0.0.0
+port = 8080
+url = http://localhost:8080/?a=b
+
+[ empty ]
+
+[server]
+port = 9090
+[db]
+	user =  admin
+password =
+`)
+
+	got, err := ReadINI(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]string{
+		"":       {"name": "demo", "debug": "true"},
+		"server": {"host": "0.0.0.0", "port": "9090", "url": "http://localhost:8080/?a=b"},
+		"empty":  {},
+		"db":     {"user": "admin", "password": ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadINI = %v, want %v", got, want)
+	}
+}
+
+func TestReadINIMalformed(t *testing.T) {
+	dir := t.TempDir()
+	for name, line := range map[string]string{
+		"missing equals": "just a line",
+		"empty key":      "= value",
+		"unterminated":   "[section",
+	} {
+		filePath := filepath.Join(dir, name)
+		writeTestFile(t, filePath, "[ok]\nkey = value\n"+line+"\n")
+
+		_, err := ReadINI(filePath)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || !strings.Contains(err.Error(), "line 3") {
+			t.Errorf("%s: got %v, want a *ParseError for line 3", name, err)
+		}
+	}
+}
+
+func TestWriteINIRoundTrip(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "app.ini")
+	data := map[string]map[string]string{
+		"":        {"name": "demo", "empty": ""},
+		"server":  {"host": "0.0.0.0", "url": "http://localhost/?a=b#top", "note": "a ; b # c"},
+		"db.main": {"user": "admin", "pass word": "s e c r e t"},
+		"[weird":  {"key]": "[value]"},
+		"no keys": {},
+		"x=y;z#w": {"k": "v"},
+	}
+	if err := WriteINI(filePath, data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadINI(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Errorf("ReadINI(WriteINI(data)) = %v, want %v", got, data)
+	}
+}
+
+func TestWriteINIInvalid(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "app.ini")
+	for name, data := range map[string]map[string]map[string]string{
+		"section spaces":     {" a ": {"k": "v"}},
+		"section trailing":   {"a\t": {"k": "v"}},
+		"section bracket":    {"a]b": {"k": "v"}},
+		"section line break": {"a\nb": {"k": "v"}},
+		"key spaces":         {"a": {"k ": "v"}},
+		"key leading":        {"a": {" k": "v"}},
+		"empty key":          {"a": {"": "v"}},
+		"key equals":         {"a": {"k=1": "v"}},
+		"key header":         {"": {"[k": "v"}},
+		"key comment":        {"a": {"#k": "v"}},
+		"value spaces":       {"a": {"k": " v"}},
+		"value trailing":     {"a": {"k": "v\t"}},
+		"value line break":   {"a": {"k": "v\r\nw"}},
+	} {
+		if err := WriteINI(filePath, data); err == nil {
+			t.Errorf("%s: WriteINI(%q) succeeded", name, data)
+		}
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("rejected data was written: %v", err)
+	}
+}
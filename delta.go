@@ -0,0 +1,249 @@
+package file
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// BlockSig is the signature of a block of a file, as computed by FileSignature.
+type BlockSig struct {
+	// Index is the position of the block in the file, starting at 0.
+	Index int
+	// Offset and Size locate the block in the file. Only the last block may be shorter than the block size.
+	Offset int64
+	Size   int
+	// Weak is the rolling checksum of the block, see WeakChecksum.
+	Weak uint32
+	// Strong is the SHA-256 digest of the block.
+	Strong [sha256.Size]byte
+}
+
+// WeakChecksum returns the rsync rolling checksum of data: cheap to compute, and cheap to update
+// while sliding a window over a file byte by byte, but prone to collisions.
+func WeakChecksum(data []byte) uint32 {
+	var a, b uint32
+	n := uint32(len(data))
+	for i, c := range data {
+		a += uint32(c)
+		b += (n - uint32(i)) * uint32(c)
+	}
+	return a&0xffff | b<<16
+}
+
+// rollChecksum updates the WeakChecksum of a window of n bytes sliding by one byte, out leaving and in entering it.
+func rollChecksum(sum uint32, n int, out byte, in byte) uint32 {
+	a, b := sum&0xffff, sum>>16
+	a = (a - uint32(out) + uint32(in)) & 0xffff
+	b = (b - uint32(n)*uint32(out) + a) & 0xffff
+	return a | b<<16
+}
+
+// FileSignature splits given file into blocks of blockSize bytes and returns their signatures,
+// from which Delta finds the blocks another file has in common with it.
+func FileSignature(filePath string, blockSize int) ([]BlockSig, error) {
+	return DefaultFS.FileSignature(filePath, blockSize)
+}
+
+// FileSignature is like the package-level FileSignature but operates on f.
+func (f *FS) FileSignature(filePath string, blockSize int) (sigs []BlockSig, err error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("invalid block size %d", blockSize)
+	}
+
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	buf := make([]byte, blockSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(file, buf)
+		if n > 0 {
+			sigs = append(sigs, BlockSig{
+				Index:  len(sigs),
+				Offset: offset,
+				Size:   n,
+				Weak:   WeakChecksum(buf[:n]),
+				Strong: sha256.Sum256(buf[:n]),
+			})
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sigs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// OpKind is the kind of an Op.
+type OpKind int
+
+const (
+	// OpCopy copies a range of the old file.
+	OpCopy OpKind = iota
+	// OpInsert inserts literal data.
+	OpInsert
+)
+
+// Op is an operation of a delta, rebuilding a new file from an old one.
+type Op struct {
+	Kind OpKind
+	// Offset and Size locate the range of the old file to copy, for OpCopy.
+	Offset int64
+	Size   int
+	// Data is the literal data to insert, for OpInsert.
+	Data []byte
+}
+
+// Delta computes the operations rebuilding newFilePath from the old file oldSigs was computed on:
+// blocks found in both are copied from the old file, the rest is inserted.
+// Running ApplyDelta with them on the old file then gives the new file, while only the inserted data had to be
+// transferred. The new file is read into memory.
+func Delta(oldSigs []BlockSig, newFilePath string) ([]Op, error) {
+	return DefaultFS.Delta(oldSigs, newFilePath)
+}
+
+// Delta is like the package-level Delta but operates on f.
+func (f *FS) Delta(oldSigs []BlockSig, newFilePath string) ([]Op, error) {
+	content, err := f.Read(newFilePath)
+	if err != nil {
+		return nil, err
+	}
+	data := []byte(content)
+
+	if len(oldSigs) == 0 {
+		if len(data) == 0 {
+			return nil, nil
+		}
+		return []Op{{Kind: OpInsert, Data: data}}, nil
+	}
+
+	bySum := make(map[uint32][]BlockSig)
+	for _, sig := range oldSigs {
+		bySum[sig.Weak] = append(bySum[sig.Weak], sig)
+	}
+	var ops []Op
+	match := func(window []byte, weak uint32) (BlockSig, bool) {
+		candidates := bySum[weak]
+		if len(candidates) == 0 {
+			return BlockSig{}, false
+		}
+		// Prefer the block following the last copied one, so copies merge.
+		next := int64(-1)
+		if last := len(ops) - 1; last >= 0 && ops[last].Kind == OpCopy {
+			next = ops[last].Offset + int64(ops[last].Size)
+		}
+		strong := sha256.Sum256(window)
+		found, ok := BlockSig{}, false
+		for _, sig := range candidates {
+			if sig.Size == len(window) && sig.Strong == strong {
+				if sig.Offset == next {
+					return sig, true
+				}
+				if !ok {
+					found, ok = sig, true
+				}
+			}
+		}
+		return found, ok
+	}
+
+	addCopy := func(sig BlockSig) {
+		if last := len(ops) - 1; last >= 0 && ops[last].Kind == OpCopy && ops[last].Offset+int64(ops[last].Size) == sig.Offset {
+			ops[last].Size += sig.Size
+			return
+		}
+		ops = append(ops, Op{Kind: OpCopy, Offset: sig.Offset, Size: sig.Size})
+	}
+	pending := 0
+	flushInsert := func(end int) {
+		if end > pending {
+			ops = append(ops, Op{Kind: OpInsert, Data: data[pending:end]})
+		}
+	}
+
+	blockSize := oldSigs[0].Size
+	p := 0
+	var weak uint32
+	rolled := false
+	for p+blockSize <= len(data) {
+		if !rolled {
+			weak = WeakChecksum(data[p : p+blockSize])
+		}
+		if sig, ok := match(data[p:p+blockSize], weak); ok {
+			flushInsert(p)
+			addCopy(sig)
+			p += blockSize
+			pending = p
+			rolled = false
+			continue
+		}
+		if p+blockSize < len(data) {
+			weak = rollChecksum(weak, blockSize, data[p], data[p+blockSize])
+			rolled = true
+		}
+		p++
+	}
+
+	// The last block of the old file may be shorter than the others.
+	if tail := data[p:]; len(tail) > 0 && pending <= p {
+		if sig, ok := match(tail, WeakChecksum(tail)); ok {
+			flushInsert(p)
+			addCopy(sig)
+			pending = len(data)
+		}
+	}
+	flushInsert(len(data))
+	return ops, nil
+}
+
+// ApplyDelta rebuilds a file from oldFilePath and ops computed by Delta, writing it atomically into dstFilePath.
+func ApplyDelta(oldFilePath string, ops []Op, dstFilePath string) error {
+	return DefaultFS.ApplyDelta(oldFilePath, ops, dstFilePath)
+}
+
+// ApplyDelta is like the package-level ApplyDelta but operates on f.
+func (f *FS) ApplyDelta(oldFilePath string, ops []Op, dstFilePath string) (err error) {
+	oldFile, err := f.fs.Open(oldFilePath)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := oldFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	return f.writeAtomic(dstFilePath, func(w io.Writer) error {
+		for _, op := range ops {
+			switch op.Kind {
+			case OpCopy:
+				n, err := io.Copy(w, io.NewSectionReader(oldFile, op.Offset, int64(op.Size)))
+				if err != nil {
+					return err
+				}
+				if n != int64(op.Size) {
+					return fmt.Errorf("delta copies %d bytes at offset %d beyond end of %s", op.Size, op.Offset, oldFilePath)
+				}
+			case OpInsert:
+				if _, err := w.Write(op.Data); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown delta op kind %d", op.Kind)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,140 @@
+package file
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileSignature(t *testing.T) {
+	dir := t.TempDir()
+	data := strings.Repeat("0123456789abcdef", 640) + "tail"
+	const blockSize = 1024
+	aPath, bPath, cPath := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	writeTestFile(t, aPath, data)
+	writeTestFile(t, bPath, data)
+	writeTestFile(t, cPath, data[:5000]+"X"+data[5001:])
+
+	aSigs, err := FileSignature(aPath, blockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aSigs) != 11 {
+		t.Fatalf("FileSignature gave %d blocks, want 11", len(aSigs))
+	}
+	for i, sig := range aSigs {
+		if sig.Index != i || sig.Offset != int64(i*blockSize) {
+			t.Errorf("block %d: Index = %d, Offset = %d", i, sig.Index, sig.Offset)
+		}
+		if sig.Weak != WeakChecksum([]byte(data[sig.Offset:sig.Offset+int64(sig.Size)])) {
+			t.Errorf("block %d: Weak doesn't match WeakChecksum of the block", i)
+		}
+	}
+	if last := aSigs[len(aSigs)-1]; last.Size != len(data)-10*blockSize {
+		t.Errorf("last block size = %d, want %d", last.Size, len(data)-10*blockSize)
+	}
+
+	bSigs, err := FileSignature(bPath, blockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(aSigs, bSigs) {
+		t.Error("identical files have different signatures")
+	}
+
+	cSigs, err := FileSignature(cPath, blockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed []int
+	for i := range aSigs {
+		if aSigs[i].Strong != cSigs[i].Strong {
+			changed = append(changed, i)
+		}
+	}
+	if want := []int{5000 / blockSize}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("one byte change altered blocks %v, want %v", changed, want)
+	}
+
+	if _, err := FileSignature(aPath, 0); err == nil {
+		t.Error("FileSignature with block size 0 succeeded")
+	}
+}
+
+func TestRollChecksum(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	const n = 8
+	sum := WeakChecksum(data[:n])
+	for i := 1; i+n <= len(data); i++ {
+		sum = rollChecksum(sum, n, data[i-1], data[i+n-1])
+		if want := WeakChecksum(data[i : i+n]); sum != want {
+			t.Fatalf("rolled checksum at %d = %#x, want %#x", i, sum, want)
+		}
+	}
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	old := sb.String()
+	const blockSize = 512
+	oldPath := filepath.Join(dir, "old")
+	writeTestFile(t, oldPath, old)
+	sigs, err := FileSignature(oldPath, blockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A change turns the blocks it touches into inserted data, and the short last block of the old file
+	// only matches at the very end of the new one.
+	for _, tt := range []struct {
+		name      string
+		data      string
+		maxInsert int
+	}{
+		{"identical", old, 0},
+		{"inserted", old[:7000] + "inserted text" + old[7000:], blockSize + len("inserted text")},
+		{"deleted", old[:3000] + old[3100:], 2 * blockSize},
+		{"appended", old + "more\n", blockSize + len("more\n")},
+		{"prefixed", "header\n" + old, len("header\n")},
+		{"truncated", old[:len(old)/2], blockSize},
+		{"empty", "", 0},
+		{"unrelated", strings.Repeat("?", 3000), 3000},
+	} {
+		newPath, dstPath := filepath.Join(dir, tt.name), filepath.Join(dir, tt.name+".rebuilt")
+		writeTestFile(t, newPath, tt.data)
+		ops, err := Delta(sigs, newPath)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		inserted := 0
+		for _, op := range ops {
+			if op.Kind == OpInsert {
+				inserted += len(op.Data)
+			}
+		}
+		if inserted > tt.maxInsert {
+			t.Errorf("%s: delta inserts %d bytes, want at most %d", tt.name, inserted, tt.maxInsert)
+		}
+
+		if err := ApplyDelta(oldPath, ops, dstPath); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := readTestFile(t, dstPath); got != tt.data {
+			t.Errorf("%s: rebuilt %d bytes differing from the %d bytes new file", tt.name, len(got), len(tt.data))
+		}
+	}
+
+	ops := []Op{{Kind: OpCopy, Offset: int64(len(old)) - 10, Size: 20}}
+	if err := ApplyDelta(oldPath, ops, filepath.Join(dir, "beyond")); err == nil {
+		t.Error("ApplyDelta copying beyond the end of the old file succeeded")
+	}
+	if Exists(filepath.Join(dir, "beyond")) {
+		t.Error("failed ApplyDelta left its destination behind")
+	}
+}
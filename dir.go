@@ -0,0 +1,624 @@
+package file
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// walk walks the file tree rooted at root like filepath.Walk, but on f's FileSystem.
+func (f *FS) walk(root string, fn filepath.WalkFunc) error {
+	info, err := f.fs.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = f.walkDir(root, info, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func (f *FS) walkDir(dirPath string, info os.FileInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(dirPath, info, nil)
+	}
+
+	names, err := f.readDirNames(dirPath)
+	err1 := fn(dirPath, info, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	for _, name := range names {
+		filePath := filepath.Join(dirPath, name)
+		fileInfo, err := f.fs.Lstat(filePath)
+		if err != nil {
+			if err = fn(filePath, fileInfo, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err = f.walkDir(filePath, fileInfo, fn); err != nil {
+			if !fileInfo.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readDirNames returns the sorted names of entries in a directory.
+func (f *FS) readDirNames(dirPath string) (names []string, err error) {
+	dir, err := f.fs.Open(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if closeErr := dir.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if names, err = dir.Readdirnames(-1); err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ListJSON returns the entries of dirPath, sorted by name, as a JSON array of objects
+// with name, size, modTime, isDir and mode fields. Symlinks are described themselves, not their targets,
+// and mode is formatted like ls does, e.g. "-rw-r--r--".
+func ListJSON(dirPath string) ([]byte, error) {
+	return DefaultFS.ListJSON(dirPath)
+}
+
+// ListJSON is like the package-level ListJSON but operates on f.
+func (f *FS) ListJSON(dirPath string) ([]byte, error) {
+	type entry struct {
+		Name    string    `json:"name"`
+		Size    int64     `json:"size"`
+		ModTime time.Time `json:"modTime"`
+		IsDir   bool      `json:"isDir"`
+		Mode    string    `json:"mode"`
+	}
+
+	names, err := f.readDirNames(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]entry, 0, len(names))
+	for _, name := range names {
+		info, err := f.fs.Lstat(filepath.Join(dirPath, name))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{
+			Name:    name,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   info.IsDir(),
+			Mode:    info.Mode().String(),
+		})
+	}
+	return json.Marshal(entries)
+}
+
+// FileStamp is the size and modification time of a file recorded by Snapshot.
+type FileStamp struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Snapshot records the size and modification time of every regular file under dirPath,
+// keyed by path relative to dirPath. It is meant to be compared later with ChangedSince.
+func Snapshot(dirPath string) (map[string]FileStamp, error) {
+	return DefaultFS.Snapshot(dirPath)
+}
+
+// Snapshot is like the package-level Snapshot but operates on f.
+func (f *FS) Snapshot(dirPath string) (map[string]FileStamp, error) {
+	stamps := make(map[string]FileStamp)
+	err := f.walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dirPath, filePath)
+		if err != nil {
+			return err
+		}
+		stamps[relPath] = FileStamp{Size: info.Size(), ModTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stamps, nil
+}
+
+// ChangedSince compares regular files under dirPath with a previous Snapshot of it,
+// and returns the sorted relative paths of files added, modified and removed since then.
+// A file is considered modified when its size or modification time changed.
+func ChangedSince(dirPath string, prev map[string]FileStamp) (added, modified, removed []string, err error) {
+	return DefaultFS.ChangedSince(dirPath, prev)
+}
+
+// ChangedSince is like the package-level ChangedSince but operates on f.
+func (f *FS) ChangedSince(dirPath string, prev map[string]FileStamp) (added, modified, removed []string, err error) {
+	current, err := f.Snapshot(dirPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for relPath, stamp := range current {
+		prevStamp, ok := prev[relPath]
+		switch {
+		case !ok:
+			added = append(added, relPath)
+		case stamp.Size != prevStamp.Size || !stamp.ModTime.Equal(prevStamp.ModTime):
+			modified = append(modified, relPath)
+		}
+	}
+	for relPath := range prev {
+		if _, ok := current[relPath]; !ok {
+			removed = append(removed, relPath)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(modified)
+	sort.Strings(removed)
+	return added, modified, removed, nil
+}
+
+// MergeDir copies the tree under srcDirPath into dstDirPath, which may already exist.
+// Source files overwrite destination files of the same relative path,
+// while destination files missing in the source are left intact.
+// Only directories and regular files are copied.
+func MergeDir(srcDirPath string, dstDirPath string) error {
+	return DefaultFS.MergeDir(srcDirPath, dstDirPath)
+}
+
+// MergeDir is like the package-level MergeDir but operates on f.
+func (f *FS) MergeDir(srcDirPath string, dstDirPath string) error {
+	return f.walk(srcDirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDirPath, filePath)
+		if err != nil {
+			return err
+		}
+		dstFilePath := filepath.Join(dstDirPath, relPath)
+
+		switch {
+		case info.IsDir():
+			return f.MakeDir(dstFilePath)
+		case info.Mode().IsRegular():
+			return f.Copy(filePath, dstFilePath)
+		default:
+			return nil
+		}
+	})
+}
+
+// Find walks the tree rooted at root and returns paths for which match returns true.
+// Entries that can't be read are skipped, and their errors are returned together as a MultiError
+// along with the paths found elsewhere.
+func Find(root string, match func(filePath string, info os.FileInfo) bool) ([]string, error) {
+	return DefaultFS.Find(root, match)
+}
+
+// Find is like the package-level Find but operates on f.
+func (f *FS) Find(root string, match func(filePath string, info os.FileInfo) bool) (filePaths []string, err error) {
+	var errs MultiError
+	err = f.walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if match(filePath, info) {
+			filePaths = append(filePaths, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return filePaths, errs.errOrNil()
+}
+
+// FindModifiedBetween returns regular files under dirPath last modified within [start, end].
+// Errors are handled like Find.
+func FindModifiedBetween(dirPath string, start time.Time, end time.Time) ([]string, error) {
+	return DefaultFS.FindModifiedBetween(dirPath, start, end)
+}
+
+// FindModifiedBetween is like the package-level FindModifiedBetween but operates on f.
+func (f *FS) FindModifiedBetween(dirPath string, start time.Time, end time.Time) ([]string, error) {
+	return f.Find(dirPath, func(filePath string, info os.FileInfo) bool {
+		modTime := info.ModTime()
+		return info.Mode().IsRegular() && !modTime.Before(start) && !modTime.After(end)
+	})
+}
+
+// TotalLines returns the sum of line counts of regular files under dirPath, counting files concurrently.
+// If suffix is not empty, only files of specified suffix are counted.
+func TotalLines(dirPath string, suffix string) (int, error) {
+	return DefaultFS.TotalLines(dirPath, suffix)
+}
+
+// TotalLines is like the package-level TotalLines but operates on f.
+func (f *FS) TotalLines(dirPath string, suffix string) (int, error) {
+	var filePaths []string
+	err := f.walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && (suffix == "" || filepath.Ext(filePath) == suffix) {
+			filePaths = append(filePaths, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		total    int
+		firstErr error
+	)
+	jobs := make(chan string)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for filePath := range jobs {
+				count, err := f.CountLine(filePath)
+				mu.Lock()
+				total += count
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, filePath := range filePaths {
+		jobs <- filePath
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	return total, nil
+}
+
+// DirSize returns the total size of regular files under dirPath.
+func DirSize(dirPath string) (int64, error) {
+	return DefaultFS.DirSize(dirPath)
+}
+
+// DirSize is like the package-level DirSize but operates on f.
+func (f *FS) DirSize(dirPath string) (int64, error) {
+	files, err := f.regularFiles(dirPath)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, file := range files {
+		size += file.info.Size()
+	}
+	return size, nil
+}
+
+// GroupByExt returns the regular files in dirPath grouped by their lowercased extension, dot included.
+// Files without extension are grouped under the empty string. Subdirectories are looked into only if recursive is set.
+func GroupByExt(dirPath string, recursive bool) (map[string][]string, error) {
+	return DefaultFS.GroupByExt(dirPath, recursive)
+}
+
+// GroupByExt is like the package-level GroupByExt but operates on f.
+func (f *FS) GroupByExt(dirPath string, recursive bool) (map[string][]string, error) {
+	groups := make(map[string][]string)
+	err := f.walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && !recursive && filePath != dirPath {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() {
+			ext := strings.ToLower(filepath.Ext(filePath))
+			groups[ext] = append(groups[ext], filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// SizeByExt returns the total size of regular files under dirPath, keyed by their lowercased extension, dot included.
+// Files without extension are counted under the empty string.
+func SizeByExt(dirPath string) (map[string]int64, error) {
+	return DefaultFS.SizeByExt(dirPath)
+}
+
+// SizeByExt is like the package-level SizeByExt but operates on f.
+func (f *FS) SizeByExt(dirPath string) (map[string]int64, error) {
+	files, err := f.regularFiles(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64)
+	for _, file := range files {
+		sizes[strings.ToLower(filepath.Ext(file.path))] += file.info.Size()
+	}
+	return sizes, nil
+}
+
+// EnforceDirSizeLimit removes regular files under dirPath, least recently modified first,
+// until DirSize of dirPath is at most maxBytes. It returns the removed files.
+func EnforceDirSizeLimit(dirPath string, maxBytes int64) ([]string, error) {
+	return DefaultFS.EnforceDirSizeLimit(dirPath, maxBytes)
+}
+
+// EnforceDirSizeLimit is like the package-level EnforceDirSizeLimit but operates on f.
+func (f *FS) EnforceDirSizeLimit(dirPath string, maxBytes int64) (removed []string, err error) {
+	files, err := f.regularFiles(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	for _, file := range files {
+		size += file.info.Size()
+	}
+
+	sortOldestFirst(files)
+	for _, file := range files {
+		if size <= maxBytes {
+			break
+		}
+		if err = f.fs.Remove(file.path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, file.path)
+		size -= file.info.Size()
+	}
+	return removed, nil
+}
+
+// EnforceDirFileLimit removes regular files under dirPath, least recently modified first,
+// until at most maxFiles of them remain. It returns the removed files.
+func EnforceDirFileLimit(dirPath string, maxFiles int) ([]string, error) {
+	return DefaultFS.EnforceDirFileLimit(dirPath, maxFiles)
+}
+
+// EnforceDirFileLimit is like the package-level EnforceDirFileLimit but operates on f.
+func (f *FS) EnforceDirFileLimit(dirPath string, maxFiles int) (removed []string, err error) {
+	files, err := f.regularFiles(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxFiles < 0 {
+		maxFiles = 0
+	}
+	sortOldestFirst(files)
+	for i := 0; i < len(files)-maxFiles; i++ {
+		if err = f.fs.Remove(files[i].path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, files[i].path)
+	}
+	return removed, nil
+}
+
+// fileEntry is a file found while walking a tree.
+type fileEntry struct {
+	path string
+	info os.FileInfo
+}
+
+// regularFiles returns all regular files under dirPath.
+func (f *FS) regularFiles(dirPath string) (files []fileEntry, err error) {
+	err = f.walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, fileEntry{path: filePath, info: info})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// sortOldestFirst sorts files by modification time, then by path for files modified at the same time.
+func sortOldestFirst(files []fileEntry) {
+	sort.Slice(files, func(i, j int) bool {
+		ti, tj := files[i].info.ModTime(), files[j].info.ModTime()
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return files[i].path < files[j].path
+	})
+}
+
+// SetModTimeRecursive sets access and modification times of dirPath and everything under it to t,
+// e.g. to stamp a tree for reproducible builds. Symlinks get their own times set, their targets are left untouched.
+func SetModTimeRecursive(dirPath string, t time.Time) error {
+	ts := []unix.Timespec{unix.NsecToTimespec(t.UnixNano()), unix.NsecToTimespec(t.UnixNano())}
+	return filepath.Walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err = unix.UtimesNanoAt(unix.AT_FDCWD, filePath, ts, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return &os.PathError{Op: "chtimes", Path: filePath, Err: err}
+		}
+		return nil
+	})
+}
+
+// MaxDepth returns how deep the tree under dirPath goes: 0 for an empty directory,
+// 1 when it only has direct entries, 2 when one of them is a non-empty directory, and so on.
+func MaxDepth(dirPath string) (int, error) {
+	return DefaultFS.MaxDepth(dirPath)
+}
+
+// MaxDepth is like the package-level MaxDepth but operates on f.
+func (f *FS) MaxDepth(dirPath string) (depth int, err error) {
+	err = f.walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dirPath, filePath)
+		if err != nil || relPath == "." {
+			return err
+		}
+		if d := len(strings.Split(relPath, string(filepath.Separator))); d > depth {
+			depth = d
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return depth, nil
+}
+
+// FlattenDir copies every regular file under srcDirPath directly into dstDirPath, naming it after its relative path
+// with separators replaced by sep, e.g. "a_b_c.txt" for "a/b/c.txt" with sep "_".
+// Names that are already taken are numbered like UniquePath does. dstDirPath is created if not exists.
+func FlattenDir(srcDirPath string, dstDirPath string, sep string) error {
+	return DefaultFS.FlattenDir(srcDirPath, dstDirPath, sep)
+}
+
+// FlattenDir is like the package-level FlattenDir but operates on f.
+func (f *FS) FlattenDir(srcDirPath string, dstDirPath string, sep string) error {
+	if err := f.MakeDir(dstDirPath); err != nil {
+		return err
+	}
+
+	return f.walk(srcDirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(srcDirPath, filePath)
+		if err != nil {
+			return err
+		}
+		name := strings.Join(strings.Split(relPath, string(filepath.Separator)), sep)
+		dstFilePath, err := f.UniquePath(filepath.Join(dstDirPath, name))
+		if err != nil {
+			return err
+		}
+		return f.CopyNoClobber(filePath, dstFilePath)
+	})
+}
+
+// CopyDirHardlinks copies the tree under srcDirPath into dstDirPath like MergeDir, also recreating symlinks,
+// and preserves hard links within the tree: files that are hard links to the same inode in the source
+// are copied once, and hard linked to that copy in the destination.
+func CopyDirHardlinks(srcDirPath string, dstDirPath string) error {
+	type inode struct {
+		dev uint64
+		ino uint64
+	}
+	copied := make(map[inode]string)
+
+	return filepath.Walk(srcDirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDirPath, filePath)
+		if err != nil {
+			return err
+		}
+		dstFilePath := filepath.Join(dstDirPath, relPath)
+
+		switch {
+		case info.IsDir():
+			return MakeDir(dstFilePath)
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(filePath)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dstFilePath)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok || uint64(st.Nlink) < 2 {
+			return Copy(filePath, dstFilePath)
+		}
+		key := inode{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+		if linked, ok := copied[key]; ok {
+			return os.Link(linked, dstFilePath)
+		}
+		if err = Copy(filePath, dstFilePath); err != nil {
+			return err
+		}
+		copied[key] = dstFilePath
+		return nil
+	})
+}
+
+// SameDir reports whether dirPath1 and dirPath2 are the same directory, following symlinks,
+// by comparing their device and inode numbers. This also detects a directory mounted twice with a bind mount.
+// It fails with syscall.ENOTDIR, wrapped in a *os.PathError, if either path is not a directory.
+func SameDir(dirPath1 string, dirPath2 string) (bool, error) {
+	st1, err := statDir(dirPath1)
+	if err != nil {
+		return false, err
+	}
+	st2, err := statDir(dirPath2)
+	if err != nil {
+		return false, err
+	}
+	return st1.Dev == st2.Dev && st1.Ino == st2.Ino, nil
+}
+
+func statDir(dirPath string) (st unix.Stat_t, err error) {
+	if err = unix.Stat(dirPath, &st); err != nil {
+		return st, &os.PathError{Op: "stat", Path: dirPath, Err: err}
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		return st, &os.PathError{Op: "stat", Path: dirPath, Err: syscall.ENOTDIR}
+	}
+	return st, nil
+}
@@ -0,0 +1,553 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writeTestTree creates files under dir, keyed by slash-separated relative path, creating directories as needed.
+func writeTestTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for relPath, data := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, filePath, data)
+	}
+}
+
+func TestChangedSince(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, map[string]string{
+		"keep.txt":      "keep",
+		"sub/edit.txt":  "before",
+		"sub/gone.txt":  "gone",
+		"sub/touch.txt": "touch",
+	})
+	prev, err := Snapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prev) != 4 {
+		t.Fatalf("Snapshot recorded %d files, want 4", len(prev))
+	}
+
+	writeTestFile(t, filepath.Join(dir, "sub", "edit.txt"), "after the edit")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "sub", "touch.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "sub", "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestTree(t, dir, map[string]string{"new.txt": "new", "sub/deeper/new.txt": "new"})
+
+	added, modified, removed, err := ChangedSince(dir, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := filepath.Join
+	if want := []string{"new.txt", join("sub", "deeper", "new.txt")}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added = %v, want %v", added, want)
+	}
+	if want := []string{join("sub", "edit.txt"), join("sub", "touch.txt")}; !reflect.DeepEqual(modified, want) {
+		t.Errorf("modified = %v, want %v", modified, want)
+	}
+	if want := []string{join("sub", "gone.txt")}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+}
+
+// readTestTree returns the content of every regular file under dir, keyed by slash-separated relative path.
+func readTestTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(relPath)] = readTestFile(t, filePath)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestMergeDir(t *testing.T) {
+	srcDir, dstDir := filepath.Join(t.TempDir(), "src"), filepath.Join(t.TempDir(), "dst")
+	writeTestTree(t, srcDir, map[string]string{
+		"both.txt":     "from src",
+		"src.txt":      "src only",
+		"sub/both.txt": "from src",
+	})
+	writeTestTree(t, dstDir, map[string]string{
+		"both.txt":     "from dst, longer",
+		"dst.txt":      "dst only",
+		"sub/both.txt": "from dst",
+		"sub/dst.txt":  "dst only",
+	})
+
+	if err := MergeDir(srcDir, dstDir); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"both.txt":     "from src",
+		"dst.txt":      "dst only",
+		"src.txt":      "src only",
+		"sub/both.txt": "from src",
+		"sub/dst.txt":  "dst only",
+	}
+	if got := readTestTree(t, dstDir); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged tree = %v, want %v", got, want)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, map[string]string{
+		"big.bin":        strings.Repeat("x", 2048),
+		"small.txt":      "tiny",
+		"sub/big.log":    strings.Repeat("y", 4096),
+		"sub/medium.txt": strings.Repeat("z", 1000),
+	})
+
+	found, err := Find(dir, func(filePath string, info os.FileInfo) bool {
+		return info.Mode().IsRegular() && info.Size() > 1024
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "big.bin"), filepath.Join(dir, "sub", "big.log")}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("Find = %v, want %v", found, want)
+	}
+
+	missing := filepath.Join(dir, "missing")
+	found, err = Find(missing, func(string, os.FileInfo) bool { return true })
+	if len(found) != 0 || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Find(missing) = %v, %v; want nothing and os.ErrNotExist", found, err)
+	}
+}
+
+func TestFindModifiedBetween(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for name, mtime := range map[string]time.Time{
+		"before.log":    base.Add(-time.Hour),
+		"start.log":     base,
+		"sub/in.log":    base.Add(30 * time.Minute),
+		"end.log":       base.Add(time.Hour),
+		"after.log":     base.Add(time.Hour + time.Second),
+		"sub/after.log": base.Add(48 * time.Hour),
+	} {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		writeTestTree(t, dir, map[string]string{name: name})
+		if err := os.Chtimes(filePath, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := FindModifiedBetween(dir, base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "end.log"), filepath.Join(dir, "start.log"), filepath.Join(dir, "sub", "in.log")}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("FindModifiedBetween = %v, want %v", found, want)
+	}
+}
+
+func TestTotalLines(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, map[string]string{
+		"a.go":       "package a\n\nfunc A() {}\n",
+		"b.go":       "package b\nvar b = 1",
+		"sub/c.go":   "package c\n" + strings.Repeat("x", 10000) + "\n",
+		"sub/d.go":   "",
+		"notes.txt":  "not\ncounted\n",
+		"sub/e.md":   "ignored\n",
+		"sub/x/f.go": strings.Repeat("line\n", 100),
+	})
+
+	total, err := TotalLines(dir, ".go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 + 2 + 2 (a long line counts once) + 0 + 100.
+	if total != 107 {
+		t.Errorf("TotalLines(.go) = %d, want 107", total)
+	}
+
+	total, err = TotalLines(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 110 {
+		t.Errorf("TotalLines of all files = %d, want 110", total)
+	}
+}
+
+// writeAgedFiles creates files under dir with the given content, the first one modified longest ago.
+func writeAgedFiles(t *testing.T, dir string, names []string, data string) {
+	t.Helper()
+	start := time.Now().Add(-time.Duration(len(names)) * time.Hour)
+	for i, name := range names {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		writeTestTree(t, dir, map[string]string{name: data})
+		mtime := start.Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(filePath, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEnforceDirSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	writeAgedFiles(t, dir, []string{"1.cache", "sub/2.cache", "3.cache", "4.cache", "sub/5.cache"}, strings.Repeat("x", 100))
+
+	if size, err := DirSize(dir); err != nil || size != 500 {
+		t.Fatalf("DirSize = %d, %v; want 500", size, err)
+	}
+
+	removed, err := EnforceDirSizeLimit(dir, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "1.cache"), filepath.Join(dir, "sub", "2.cache"), filepath.Join(dir, "3.cache")}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want the oldest %v", removed, want)
+	}
+	if size, _ := DirSize(dir); size != 200 {
+		t.Errorf("DirSize after eviction = %d, want 200", size)
+	}
+
+	if removed, err = EnforceDirSizeLimit(dir, 250); err != nil || len(removed) != 0 {
+		t.Errorf("EnforceDirSizeLimit under the cap = %v, %v; want nothing removed", removed, err)
+	}
+}
+
+func TestEnforceDirFileLimit(t *testing.T) {
+	dir := t.TempDir()
+	writeAgedFiles(t, dir, []string{"app.log.1", "app.log.2", "app.log.3", "app.log.4", "app.log.5"}, "log")
+
+	removed, err := EnforceDirFileLimit(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "app.log.1"), filepath.Join(dir, "app.log.2"), filepath.Join(dir, "app.log.3")}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	if kept := readTestTree(t, dir); len(kept) != 2 || kept["app.log.4"] == "" || kept["app.log.5"] == "" {
+		t.Errorf("kept files = %v, want the newest two", kept)
+	}
+
+	if removed, err = EnforceDirFileLimit(dir, -1); err != nil || len(removed) != 2 {
+		t.Errorf("EnforceDirFileLimit(-1) = %v, %v; want every file removed", removed, err)
+	}
+}
+
+func TestSetModTimeRecursive(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, map[string]string{"a": "a", "sub/b": "b", "sub/deeper/c": "c"})
+	target := filepath.Join(t.TempDir(), "target")
+	writeTestFile(t, target, "outside")
+	if err := os.Symlink(target, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := SetModTimeRecursive(dir, stamp); err != nil {
+		t.Fatal(err)
+	}
+	for _, relPath := range []string{".", "a", "sub", "sub/b", "sub/deeper", "sub/deeper/c", "link"} {
+		info, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(relPath)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(stamp) {
+			t.Errorf("%s: mtime = %v, want %v", relPath, info.ModTime(), stamp)
+		}
+	}
+	if info, err := os.Stat(target); err != nil || !info.ModTime().Equal(targetInfo.ModTime()) {
+		t.Errorf("symlink target mtime changed: %v, %v", info, err)
+	}
+
+	if err := SetModTimeRecursive(filepath.Join(dir, "missing"), stamp); !os.IsNotExist(err) {
+		t.Errorf("SetModTimeRecursive of a missing directory: got %v, want not exist", err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	dir := t.TempDir()
+	if depth, err := MaxDepth(dir); err != nil || depth != 0 {
+		t.Errorf("MaxDepth(empty) = %d, %v; want 0", depth, err)
+	}
+
+	writeTestTree(t, dir, map[string]string{"a": "", "b/c": ""})
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if depth, err := MaxDepth(dir); err != nil || depth != 2 {
+		t.Errorf("MaxDepth = %d, %v; want 2", depth, err)
+	}
+
+	writeTestTree(t, dir, map[string]string{"b/d/e/f": ""})
+	if depth, err := MaxDepth(dir); err != nil || depth != 4 {
+		t.Errorf("MaxDepth = %d, %v; want 4", depth, err)
+	}
+	if _, err := MaxDepth(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("MaxDepth(missing): got %v, want not exist", err)
+	}
+}
+
+func TestFlattenDir(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), filepath.Join(t.TempDir(), "flat")
+	writeTestTree(t, srcDir, map[string]string{
+		"top.txt":      "top",
+		"a/b.txt":      "nested",
+		"a_b.txt":      "clashing",
+		"a/c/d/e.json": "deep",
+	})
+
+	if err := FlattenDir(srcDir, dstDir, "_"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"top.txt":      "top",
+		"a_b.txt":      "nested",
+		"a_b (1).txt":  "clashing",
+		"a_c_d_e.json": "deep",
+	}
+	if got := readTestTree(t, dstDir); !reflect.DeepEqual(got, want) {
+		t.Errorf("flattened tree = %v, want %v", got, want)
+	}
+}
+
+func TestCopyDirHardlinks(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), filepath.Join(t.TempDir(), "copy")
+	writeTestTree(t, srcDir, map[string]string{"a": "shared", "sub/other": "other"})
+	if err := os.Link(filepath.Join(srcDir, "a"), filepath.Join(srcDir, "sub", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("other", filepath.Join(srcDir, "sub", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CopyDirHardlinks(srcDir, dstDir); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "shared", "sub/b": "shared", "sub/other": "other"}
+	if got := readTestTree(t, dstDir); !reflect.DeepEqual(got, want) {
+		t.Errorf("copied tree = %v, want %v", got, want)
+	}
+
+	a, err := os.Stat(filepath.Join(dstDir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.Stat(filepath.Join(dstDir, "sub", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := os.Stat(filepath.Join(dstDir, "sub", "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(a, b) {
+		t.Error("hard linked sources were copied to separate files")
+	}
+	if os.SameFile(a, other) {
+		t.Error("unrelated files share an inode")
+	}
+	if src, err := os.Stat(filepath.Join(srcDir, "a")); err != nil || os.SameFile(a, src) {
+		t.Error("destination is linked to the source instead of a copy")
+	}
+	if target, err := os.Readlink(filepath.Join(dstDir, "sub", "link")); err != nil || target != "other" {
+		t.Errorf("symlink copied as %q, %v; want %q", target, err, "other")
+	}
+}
+
+func TestGroupByExt(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, map[string]string{
+		"a.txt":       "",
+		"B.TXT":       "",
+		"c.go":        "",
+		"Makefile":    "",
+		"sub/d.txt":   "",
+		"sub/e.json":  "",
+		"sub/deep/f":  "",
+		".hidden.txt": "",
+	})
+	join := func(names ...string) []string {
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(dir, filepath.FromSlash(name))
+		}
+		return paths
+	}
+
+	for _, tt := range []struct {
+		recursive bool
+		want      map[string][]string
+	}{
+		{false, map[string][]string{
+			".txt": join(".hidden.txt", "B.TXT", "a.txt"),
+			".go":  join("c.go"),
+			"":     join("Makefile"),
+		}},
+		{true, map[string][]string{
+			".txt":  join(".hidden.txt", "B.TXT", "a.txt", "sub/d.txt"),
+			".go":   join("c.go"),
+			".json": join("sub/e.json"),
+			"":      join("Makefile", "sub/deep/f"),
+		}},
+	} {
+		got, err := GroupByExt(dir, tt.recursive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GroupByExt(recursive=%v) = %v, want %v", tt.recursive, got, tt.want)
+		}
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, map[string]string{"b.txt": "hello", "a/inner": ""})
+	if err := os.Chmod(filepath.Join(dir, "b.txt"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "b.txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("b.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ListJSON(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw []map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("ListJSON gave invalid JSON %s: %v", data, err)
+	}
+	for _, fields := range raw {
+		var keys []string
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if want := []string{"isDir", "modTime", "mode", "name", "size"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("entry fields = %v, want %v", keys, want)
+		}
+	}
+
+	var entries []struct {
+		Name    string    `json:"name"`
+		Size    int64     `json:"size"`
+		ModTime time.Time `json:"modTime"`
+		IsDir   bool      `json:"isDir"`
+		Mode    string    `json:"mode"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("ListJSON listed %d entries, want 3: %s", len(entries), data)
+	}
+	if e := entries[0]; e.Name != "a" || !e.IsDir || e.Mode != "drwxr-xr-x" {
+		t.Errorf("directory entry = %+v", e)
+	}
+	if e := entries[1]; e.Name != "b.txt" || e.IsDir || e.Size != 5 || e.Mode != "-rw-r-----" || !e.ModTime.Equal(mtime) {
+		t.Errorf("file entry = %+v", e)
+	}
+	if e := entries[2]; e.Name != "link" || e.IsDir || e.Size != int64(len("b.txt")) || !strings.HasPrefix(e.Mode, "L") {
+		t.Errorf("symlink entry = %+v", e)
+	}
+
+	if _, err := ListJSON(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("ListJSON(missing): got %v, want not exist", err)
+	}
+}
+
+func TestSameDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, map[string]string{"real/file": "", "other/file": ""})
+	realDir := filepath.Join(dir, "real")
+	if err := os.Symlink(realDir, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"real", "real", true},
+		{"real", "link", true},
+		{"real", "other/../real", true},
+		{"real", "other", false},
+	} {
+		got, err := SameDir(filepath.Join(dir, tt.a), filepath.Join(dir, tt.b))
+		if err != nil || got != tt.want {
+			t.Errorf("SameDir(%s, %s) = %v, %v; want %v", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+
+	if _, err := SameDir(realDir, filepath.Join(realDir, "file")); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("SameDir with a file: got %v, want ENOTDIR", err)
+	}
+	if _, err := SameDir(filepath.Join(dir, "missing"), realDir); !os.IsNotExist(err) {
+		t.Errorf("SameDir with a missing directory: got %v, want not exist", err)
+	}
+}
+
+func TestSizeByExt(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, map[string]string{
+		"a.txt":      "12345",
+		"B.TXT":      "123",
+		"sub/c.txt":  "12",
+		"sub/d.json": "{}",
+		"Makefile":   "all:",
+		"sub/e":      "",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	sizes, err := SizeByExt(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{".txt": 10, ".json": 2, "": 4}
+	if !reflect.DeepEqual(sizes, want) {
+		t.Errorf("SizeByExt = %v, want %v", sizes, want)
+	}
+}
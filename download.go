@@ -0,0 +1,125 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Download fetches url with a GET request and stores the response body into dstFilePath atomically,
+// returning the number of bytes written. A non-2xx response is returned as an error without creating dstFilePath.
+// The request is aborted when ctx is done.
+func Download(ctx context.Context, url string, dstFilePath string) (int64, error) {
+	return DefaultFS.Download(ctx, url, dstFilePath)
+}
+
+// Download is like the package-level Download but operates on f.
+func (f *FS) Download(ctx context.Context, url string, dstFilePath string) (n int64, err error) {
+	resp, err := get(ctx, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	err = f.writeAtomic(dstFilePath, func(w io.Writer) (err error) {
+		n, err = io.Copy(w, resp.Body)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// get sends a GET request for url with given extra headers.
+func get(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// DownloadResume is like Download, but continues a previous partial download of url into dstFilePath:
+// in case dstFilePath exists, only the remaining bytes are requested with a Range header and appended to it.
+// It falls back to downloading the whole content when the server ignores the Range header.
+// It returns the number of bytes written by this call.
+func DownloadResume(ctx context.Context, url string, dstFilePath string) (int64, error) {
+	return DefaultFS.DownloadResume(ctx, url, dstFilePath)
+}
+
+// DownloadResume is like the package-level DownloadResume but operates on f.
+func (f *FS) DownloadResume(ctx context.Context, url string, dstFilePath string) (n int64, err error) {
+	info, err := f.fs.Stat(dstFilePath)
+	if os.IsNotExist(err) || (err == nil && info.Size() == 0) {
+		return f.Download(ctx, url, dstFilePath)
+	}
+	if err != nil {
+		return 0, err
+	}
+	offset := info.Size()
+
+	resp, err := get(ctx, url, http.Header{"Range": {fmt.Sprintf("bytes=%d-", offset)}})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		if contentRange := resp.Header.Get("Content-Range"); contentRangeStart(contentRange) != offset {
+			return 0, fmt.Errorf("GET %s: unexpected Content-Range %q for offset %d", url, contentRange, offset)
+		}
+		dstFile, err := f.fs.OpenFile(dstFilePath, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return 0, err
+		}
+		n, err = io.Copy(dstFile, resp.Body)
+		if closeErr := dstFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		return n, err
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && resp.Header.Get("Content-Range") == fmt.Sprintf("bytes */%d", offset):
+		// Already complete.
+		return 0, nil
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		// Range ignored, the body is the whole content.
+		err = f.writeAtomic(dstFilePath, func(w io.Writer) (err error) {
+			n, err = io.Copy(w, resp.Body)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+}
+
+// contentRangeStart returns the first byte position of a "bytes start-end/size" Content-Range header, or -1.
+func contentRangeStart(contentRange string) int64 {
+	var start, end int64
+	var size string
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%s", &start, &end, &size); err != nil {
+		return -1
+	}
+	return start
+}
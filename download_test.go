@@ -0,0 +1,114 @@
+package file
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const downloadContent = "the quick brown fox jumps over the lazy dog\n"
+
+func TestDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(downloadContent))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	dstFilePath := filepath.Join(dir, "file")
+	n, err := Download(context.Background(), srv.URL+"/file", dstFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(downloadContent)) {
+		t.Errorf("Download wrote %d bytes, want %d", n, len(downloadContent))
+	}
+	if got := readTestFile(t, dstFilePath); got != downloadContent {
+		t.Errorf("downloaded content = %q", got)
+	}
+
+	missingFilePath := filepath.Join(dir, "missing")
+	_, err = Download(context.Background(), srv.URL+"/missing", missingFilePath)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Download of 404: got %v, want an error with the status", err)
+	}
+	if Exists(missingFilePath) {
+		t.Error("Download of 404 created the destination")
+	}
+}
+
+func TestDownloadCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(downloadContent))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dstFilePath := filepath.Join(t.TempDir(), "file")
+	if _, err := Download(ctx, srv.URL, dstFilePath); err == nil {
+		t.Error("Download with a canceled context succeeded")
+	}
+	if Exists(dstFilePath) {
+		t.Error("canceled Download created the destination")
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ServeContent honors Range headers.
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(downloadContent))
+	}))
+	defer srv.Close()
+
+	dstFilePath := filepath.Join(t.TempDir(), "file")
+	writeTestFile(t, dstFilePath, downloadContent[:10])
+
+	n, err := DownloadResume(context.Background(), srv.URL, dstFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len(downloadContent) - 10); n != want {
+		t.Errorf("DownloadResume wrote %d bytes, want the remaining %d", n, want)
+	}
+	if got := readTestFile(t, dstFilePath); got != downloadContent {
+		t.Errorf("resumed content = %q, want %q", got, downloadContent)
+	}
+
+	n, err = DownloadResume(context.Background(), srv.URL, dstFilePath)
+	if err != nil || n != 0 {
+		t.Errorf("DownloadResume of complete file = %d, %v; want 0, nil", n, err)
+	}
+	if got := readTestFile(t, dstFilePath); got != downloadContent {
+		t.Errorf("complete file changed to %q", got)
+	}
+}
+
+func TestDownloadResumeRangeIgnored(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(downloadContent))
+	}))
+	defer srv.Close()
+
+	dstFilePath := filepath.Join(t.TempDir(), "file")
+	writeTestFile(t, dstFilePath, "stale partial content")
+
+	n, err := DownloadResume(context.Background(), srv.URL, dstFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(downloadContent)) {
+		t.Errorf("DownloadResume wrote %d bytes, want the whole %d", n, len(downloadContent))
+	}
+	if got := readTestFile(t, dstFilePath); got != downloadContent {
+		t.Errorf("restarted content = %q, want %q", got, downloadContent)
+	}
+}
@@ -0,0 +1,61 @@
+package file
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// DetectEncoding guesses the text encoding of a file from its first 8KB,
+// returning "utf-8", "utf-16le", "utf-16be" or "unknown".
+// A byte order mark decides if present. Otherwise valid UTF-8, including plain ASCII and empty files, gives "utf-8",
+// and mostly ASCII text with NUL bytes at every other position is taken as UTF-16 of the matching byte order.
+func DetectEncoding(filePath string) (string, error) {
+	return DefaultFS.DetectEncoding(filePath)
+}
+
+// DetectEncoding is like the package-level DetectEncoding but operates on f.
+func (f *FS) DetectEncoding(filePath string) (string, error) {
+	head, err := f.sniff(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		return "utf-8", nil
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return "utf-16le", nil
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return "utf-16be", nil
+	case bytes.IndexByte(head, 0) < 0 && utf8.Valid(head):
+		return "utf-8", nil
+	}
+
+	// Without BOM, UTF-16 of mostly ASCII text has a NUL as high byte of most characters.
+	var evenNULs, oddNULs int
+	for i, b := range head {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenNULs++
+		} else {
+			oddNULs++
+		}
+	}
+	units := len(head) / 2
+	switch {
+	case units > 0 && oddNULs > units*3/4 && evenNULs == 0:
+		return "utf-16le", nil
+	case units > 0 && evenNULs > units*3/4 && oddNULs == 0:
+		return "utf-16be", nil
+	default:
+		return "unknown", nil
+	}
+}
@@ -0,0 +1,35 @@
+package file
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name string
+		data string
+		want string
+	}{
+		{"utf-8 bom", "\xEF\xBB\xBFhello", "utf-8"},
+		{"utf-16le bom", "\xFF\xFEh\x00i\x00", "utf-16le"},
+		{"utf-16be bom", "\xFE\xFF\x00h\x00i", "utf-16be"},
+		{"ascii", "hello, world\n", "utf-8"},
+		{"utf-8", "grüße\n", "utf-8"},
+		{"empty", "", "utf-8"},
+		{"utf-16le", "h\x00e\x00l\x00l\x00o\x00", "utf-16le"},
+		{"utf-16be", "\x00h\x00e\x00l\x00l\x00o", "utf-16be"},
+		{"binary", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "unknown"},
+	} {
+		filePath := filepath.Join(dir, tt.name)
+		writeTestFile(t, filePath, tt.data)
+		got, err := DetectEncoding(filePath)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: DetectEncoding = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
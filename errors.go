@@ -0,0 +1,61 @@
+package file
+
+import (
+	"errors"
+	"strings"
+)
+
+// MultiError collects the errors of an operation that carries on past individual failures.
+// errors.Is and errors.As match it when they match any of the collected errors.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the collected errors matches target.
+func (e MultiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first collected error matching target.
+func (e MultiError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// errOrNil returns e as an error, or nil in case it is empty.
+func (e MultiError) errOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ParseError is returned when content of a file can't be parsed, as opposed to errors reading or writing it.
+type ParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return "parse " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying parse error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,22 @@
+package file
+
+import (
+	"os"
+)
+
+// OpenFileCount returns the number of file descriptors currently open in the process, by listing /proc/self/fd.
+// It helps spotting descriptor leaks, and is only supported on Linux.
+func OpenFileCount() (int, error) {
+	dir, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	defer dir.Close()
+
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return 0, err
+	}
+	// Don't count the descriptor used for listing.
+	return len(names) - 1, nil
+}
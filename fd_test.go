@@ -0,0 +1,38 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenFileCount(t *testing.T) {
+	before, err := OpenFileCount()
+	if err != nil {
+		t.Skipf("OpenFileCount not supported: %v", err)
+	}
+	if before < 3 {
+		t.Errorf("OpenFileCount = %d, want at least stdin, stdout and stderr", before)
+	}
+
+	file, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := OpenFileCount(); err != nil || n != before+1 {
+		t.Errorf("OpenFileCount with a file open = %d, %v, want %d", n, err, before+1)
+	}
+	file.Close()
+
+	dir := t.TempDir()
+	srcFilePath := filepath.Join(dir, "src")
+	writeTestFile(t, srcFilePath, "data")
+	for i := 0; i < 100; i++ {
+		if err := Copy(srcFilePath, filepath.Join(dir, "dst")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after, err := OpenFileCount(); err != nil || after != before {
+		t.Errorf("OpenFileCount after copies = %d, %v, want %d", after, err, before)
+	}
+}
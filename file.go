@@ -2,91 +2,1108 @@ package file
 
 import (
 	"bufio"
-	"fmt"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"golang.org/x/sys/unix"
 )
 
 // CountLine returns line count of given file.
-func CountLine(filePath string) (count int, err error) {
-	file, err := os.Open(filePath)
+func CountLine(filePath string) (int, error) {
+	return DefaultFS.CountLine(filePath)
+}
+
+// CountLine is like the package-level CountLine but operates on f.
+func (f *FS) CountLine(filePath string) (count int, err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return count, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	return CountLineReader(file)
+}
+
+// CountLineReader returns line count of what r yields, e.g. a gzip.Reader over a compressed file.
+func CountLineReader(r io.Reader) (count int, err error) {
+	br := bufio.NewReader(r)
+	for {
+		var isPrefix bool
+		if _, isPrefix, err = br.ReadLine(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, err
+		}
+		// A line longer than the buffer is returned in several parts, count it once.
+		if !isPrefix {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ErrLineNotFound is returned by ReadLineAt, wrapped in a *os.PathError,
+// when a file has fewer lines than requested.
+var ErrLineNotFound = errors.New("line not found")
+
+// errStop stops iterating early from within a callback.
+var errStop = errors.New("stop")
+
+// ReadLineAt returns the 1-based lineNum-th line of given file, without its line ending.
+// It stops reading as soon as the line is found.
+func ReadLineAt(filePath string, lineNum int) (string, error) {
+	return DefaultFS.ReadLineAt(filePath, lineNum)
+}
+
+// ReadLineAt is like the package-level ReadLineAt but operates on f.
+func (f *FS) ReadLineAt(filePath string, lineNum int) (line string, err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	count := 0
+	err = forEachLine(file, func(text string) error {
+		if count++; count == lineNum {
+			line = text
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		return line, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "", &os.PathError{Op: "read", Path: filePath, Err: ErrLineNotFound}
+}
+
+// GrepMatch is a line matched by GrepCount.
+type GrepMatch struct {
+	// Line is the 1-based line number.
+	Line int
+	// Text is the line content without line ending.
+	Text string
+}
+
+// GrepCount returns the count of lines in given file matching re, together with the matched lines.
+// At most maxMatches matched lines are returned, while count still includes all matching lines.
+// A negative maxMatches returns all of them.
+func GrepCount(filePath string, re *regexp.Regexp, maxMatches int) (int, []GrepMatch, error) {
+	return DefaultFS.GrepCount(filePath, re, maxMatches)
+}
+
+// GrepCount is like the package-level GrepCount but operates on f.
+func (f *FS) GrepCount(filePath string, re *regexp.Regexp, maxMatches int) (count int, matches []GrepMatch, err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	lineNum := 0
+	err = forEachLine(file, func(line string) error {
+		lineNum++
+		if !re.MatchString(line) {
+			return nil
+		}
+		count++
+		if maxMatches < 0 || len(matches) < maxMatches {
+			matches = append(matches, GrepMatch{Line: lineNum, Text: line})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return count, matches, nil
+}
+
+// CountOccurrences returns the number of non-overlapping occurrences of substr in given file,
+// including those spanning line breaks. The file is streamed, not held in memory. An empty substr counts 0.
+func CountOccurrences(filePath string, substr string) (int, error) {
+	return DefaultFS.CountOccurrences(filePath, substr)
+}
+
+// CountOccurrences is like the package-level CountOccurrences but operates on f.
+func (f *FS) CountOccurrences(filePath string, substr string) (count int, err error) {
+	if substr == "" {
+		return 0, nil
+	}
+
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	pattern := []byte(substr)
+	// buf holds the unmatched tail of the previous chunk, at most len(pattern)-1 bytes,
+	// followed by the next chunk, so occurrences spanning two chunks are found too.
+	buf := make([]byte, 0, len(pattern)-1+32*1024)
+	for {
+		n, readErr := file.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+
+		pos := 0
+		for {
+			i := bytes.Index(buf[pos:], pattern)
+			if i < 0 {
+				break
+			}
+			count++
+			pos += i + len(pattern)
+		}
+
+		if readErr == io.EOF {
+			return count, nil
+		}
+		if readErr != nil {
+			return 0, readErr
+		}
+		if keep := len(buf) - (len(pattern) - 1); pos < keep {
+			pos = keep
+		}
+		buf = buf[:copy(buf, buf[pos:])]
+	}
+}
+
+// ReadDelimited reads given file record by record, calling fn with every record split on delim.
+// The record passed to fn excludes delim, and is only valid until fn returns.
+// A final record not followed by delim is passed to fn as well.
+func ReadDelimited(filePath string, delim byte, fn func(record []byte) error) error {
+	return DefaultFS.ReadDelimited(filePath, delim, fn)
+}
+
+// ReadDelimited is like the package-level ReadDelimited but operates on f.
+func (f *FS) ReadDelimited(filePath string, delim byte, fn func(record []byte) error) (err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	br := bufio.NewReader(file)
+	for {
+		record, err := br.ReadBytes(delim)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(record) == 0 && err == io.EOF {
+			return nil
+		}
+		if fnErr := fn(bytes.TrimSuffix(record, []byte{delim})); fnErr != nil {
+			return fnErr
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// forEachLine calls fn with every line read from r, stripped of its line ending.
+// Unlike bufio.Scanner it has no limit on line length.
+func forEachLine(r io.Reader, fn func(line string) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" && err == io.EOF {
+			return nil
+		}
+		if fnErr := fn(trimLineEnding(line)); fnErr != nil {
+			return fnErr
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// trimLineEnding removes a trailing "\n" or "\r\n" from line.
+func trimLineEnding(line string) string {
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+// Copy file from srcFilePath to dstFilePath.
+// It overwrites dstFilePath in case already exists.
+func Copy(srcFilePath string, dstFilePath string) error {
+	return DefaultFS.Copy(srcFilePath, dstFilePath)
+}
+
+// Copy is like the package-level Copy but operates on f.
+func (f *FS) Copy(srcFilePath string, dstFilePath string) error {
+	_, err := f.copyFile(srcFilePath, dstFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, nil)
+	return err
+}
+
+// CopyNoClobber copies file from srcFilePath to dstFilePath like Copy,
+// but fails with an error wrapping os.ErrExist in case dstFilePath already exists.
+func CopyNoClobber(srcFilePath string, dstFilePath string) error {
+	return DefaultFS.CopyNoClobber(srcFilePath, dstFilePath)
+}
+
+// CopyNoClobber is like the package-level CopyNoClobber but operates on f.
+func (f *FS) CopyNoClobber(srcFilePath string, dstFilePath string) error {
+	_, err := f.copyFile(srcFilePath, dstFilePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, nil)
+	return err
+}
+
+// copyFile copies file from srcFilePath to dstFilePath opened with flag, and returns the number of bytes copied.
+// Content is also written to tee if not nil.
+func (f *FS) copyFile(srcFilePath string, dstFilePath string, flag int, tee io.Writer) (n int64, err error) {
+	srcFile, err := f.fs.Open(srcFilePath)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if closeErr := srcFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	var r io.Reader = srcFile
+	if tee != nil {
+		r = io.TeeReader(srcFile, tee)
+	}
+	return f.copyFrom(r, dstFilePath, flag)
+}
+
+// copyFrom copies what r yields into dstFilePath opened with flag, and returns the number of bytes copied.
+func (f *FS) copyFrom(r io.Reader, dstFilePath string, flag int) (n int64, err error) {
+	dstFile, err := f.fs.OpenFile(dstFilePath, flag, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if closeErr := dstFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	w := bufio.NewWriter(dstFile)
+	if n, err = io.Copy(w, bufio.NewReader(r)); err != nil {
+		return n, err
+	}
+	return n, w.Flush()
+}
+
+// copyTo copies content of file srcFilePath into w, and returns the number of bytes copied.
+func (f *FS) copyTo(w io.Writer, srcFilePath string) (n int64, err error) {
+	srcFile, err := f.fs.Open(srcFilePath)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if closeErr := srcFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	return io.Copy(w, srcFile)
+}
+
+// CopyFromStdin copies standard input into dstFilePath until EOF, and returns the number of bytes copied.
+// It overwrites dstFilePath in case already exists.
+func CopyFromStdin(dstFilePath string) (int64, error) {
+	return DefaultFS.CopyFromStdin(dstFilePath)
+}
+
+// CopyFromStdin is like the package-level CopyFromStdin but operates on f.
+func (f *FS) CopyFromStdin(dstFilePath string) (int64, error) {
+	return f.copyFrom(os.Stdin, dstFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+}
+
+// CopyToStdout copies content of srcFilePath to standard output, and returns the number of bytes copied.
+func CopyToStdout(srcFilePath string) (int64, error) {
+	return DefaultFS.CopyToStdout(srcFilePath)
+}
+
+// CopyToStdout is like the package-level CopyToStdout but operates on f.
+func (f *FS) CopyToStdout(srcFilePath string) (int64, error) {
+	return f.copyTo(os.Stdout, srcFilePath)
+}
+
+// CopyHash copies file from srcFilePath to dstFilePath like Copy,
+// and returns the hex-encoded SHA-256 digest of the content computed while copying.
+func CopyHash(srcFilePath string, dstFilePath string) (string, error) {
+	return DefaultFS.CopyHash(srcFilePath, dstFilePath)
+}
+
+// CopyHash is like the package-level CopyHash but operates on f.
+func (f *FS) CopyHash(srcFilePath string, dstFilePath string) (string, error) {
+	h := sha256.New()
+	if _, err := f.copyFile(srcFilePath, dstFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CopyResult tells what CopyReport did.
+type CopyResult struct {
+	// BytesCopied is the number of bytes written into the destination.
+	BytesCopied int64
+	// DstCreated is true when the destination didn't exist before.
+	DstCreated bool
+	// DstModified is true when an existing destination got a different content.
+	DstModified bool
+}
+
+// CopyReport copies file from srcFilePath to dstFilePath like Copy, and reports whether the destination changed.
+// An existing destination with the same content as the source is left untouched.
+func CopyReport(srcFilePath string, dstFilePath string) (CopyResult, error) {
+	return DefaultFS.CopyReport(srcFilePath, dstFilePath)
+}
+
+// CopyReport is like the package-level CopyReport but operates on f.
+func (f *FS) CopyReport(srcFilePath string, dstFilePath string) (result CopyResult, err error) {
+	if _, err = f.fs.Stat(dstFilePath); err != nil {
+		if !os.IsNotExist(err) {
+			return result, err
+		}
+		result.DstCreated = true
+	} else {
+		same, err := f.sameContent(srcFilePath, dstFilePath)
+		if err != nil || same {
+			return result, err
+		}
+		result.DstModified = true
+	}
+
+	if result.BytesCopied, err = f.copyFile(srcFilePath, dstFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, nil); err != nil {
+		return CopyResult{}, err
+	}
+	return result, nil
+}
+
+// sameContent checks if two files have the same content.
+func (f *FS) sameContent(filePath1 string, filePath2 string) (same bool, err error) {
+	file1, err := f.fs.Open(filePath1)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if closeErr := file1.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	file2, err := f.fs.Open(filePath2)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if closeErr := file2.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	info1, err := file1.Stat()
+	if err != nil {
+		return false, err
+	}
+	info2, err := file2.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info1.Size() != info2.Size() {
+		return false, nil
+	}
+
+	return sameReaderContent(file1, file2)
+}
+
+// sameReaderContent checks if two readers yield the same bytes.
+func sameReaderContent(r1 io.Reader, r2 io.Reader) (bool, error) {
+	buf1, buf2 := make([]byte, 32<<10), make([]byte, 32<<10)
+	for {
+		n1, err1 := io.ReadFull(r1, buf1)
+		if err1 != nil && err1 != io.EOF && err1 != io.ErrUnexpectedEOF {
+			return false, err1
+		}
+		n2, err2 := io.ReadFull(r2, buf2)
+		if err2 != nil && err2 != io.EOF && err2 != io.ErrUnexpectedEOF {
+			return false, err2
+		}
+		if !bytes.Equal(buf1[:n1], buf2[:n2]) {
+			return false, nil
+		}
+		if err1 != nil || err2 != nil {
+			return err1 != nil && err2 != nil, nil
+		}
+	}
+}
+
+// CopyPreserveTimes copies file from srcFilePath to dstFilePath like Copy,
+// then sets access and modification times of dstFilePath to those of srcFilePath.
+func CopyPreserveTimes(srcFilePath string, dstFilePath string) error {
+	atime, mtime, err := fileTimes(srcFilePath)
+	if err != nil {
+		return err
+	}
+	if err = Copy(srcFilePath, dstFilePath); err != nil {
+		return err
+	}
+	return os.Chtimes(dstFilePath, atime, mtime)
+}
+
+// CopyMeta applies permissions, ownership and timestamps of srcFilePath to the existing dstFilePath,
+// leaving its content untouched. Changing ownership usually requires privileges, so it is skipped
+// when not permitted.
+func CopyMeta(srcFilePath string, dstFilePath string) error {
+	info, err := os.Stat(srcFilePath)
+	if err != nil {
+		return err
+	}
+	var st unix.Stat_t
+	if err = unix.Stat(srcFilePath, &st); err != nil {
+		return &os.PathError{Op: "stat", Path: srcFilePath, Err: err}
+	}
+
+	// Chown first, as it may clear setuid and setgid bits.
+	if err = os.Chown(dstFilePath, int(st.Uid), int(st.Gid)); err != nil && !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	if err = os.Chmod(dstFilePath, info.Mode()); err != nil {
+		return err
+	}
+	return os.Chtimes(dstFilePath, time.Unix(st.Atim.Unix()), time.Unix(st.Mtim.Unix()))
+}
+
+// fileTimes returns access and modification times of a file.
+func fileTimes(filePath string) (atime time.Time, mtime time.Time, err error) {
+	var st unix.Stat_t
+	if err = unix.Stat(filePath, &st); err != nil {
+		return atime, mtime, &os.PathError{Op: "stat", Path: filePath, Err: err}
+	}
+	return time.Unix(st.Atim.Unix()), time.Unix(st.Mtim.Unix()), nil
+}
+
+// CopyToDir copies srcFilePath into dstDirPath, keeping the source file name.
+// It creates dstDirPath if not exists, and returns path of the copied file.
+func CopyToDir(srcFilePath string, dstDirPath string) (string, error) {
+	return DefaultFS.CopyToDir(srcFilePath, dstDirPath)
+}
+
+// CopyToDir is like the package-level CopyToDir but operates on f.
+func (f *FS) CopyToDir(srcFilePath string, dstDirPath string) (string, error) {
+	if err := f.MakeDir(dstDirPath); err != nil {
+		return "", err
+	}
+
+	dstFilePath := filepath.Join(dstDirPath, filepath.Base(srcFilePath))
+	if err := f.Copy(srcFilePath, dstFilePath); err != nil {
+		return "", err
+	}
+	return dstFilePath, nil
+}
+
+// CopyMkdir copies file from srcFilePath to dstFilePath like Copy,
+// creating the parent directories of dstFilePath first if not exist.
+func CopyMkdir(srcFilePath string, dstFilePath string) error {
+	return DefaultFS.CopyMkdir(srcFilePath, dstFilePath)
+}
+
+// CopyMkdir is like the package-level CopyMkdir but operates on f.
+func (f *FS) CopyMkdir(srcFilePath string, dstFilePath string) error {
+	if err := f.EnsureParentDir(dstFilePath); err != nil {
+		return err
+	}
+	return f.Copy(srcFilePath, dstFilePath)
+}
+
+// RestoreFile copies srcFilePath to dstFilePath like CopyPreserveTimes, also applying the permission bits of srcFilePath.
+// Missing parent directories of dstFilePath are created with the permission bits of the matching parent
+// directories of srcFilePath, or 0755 where there is no such directory.
+func RestoreFile(srcFilePath string, dstFilePath string) error {
+	info, err := os.Stat(srcFilePath)
+	if err != nil {
+		return err
+	}
+
+	// Collect missing parents from the deepest up, paired with the source directory at the same level.
+	type dirPair struct {
+		dst  string
+		perm os.FileMode
+	}
+	var missing []dirPair
+	srcDir, dstDir := filepath.Dir(srcFilePath), filepath.Dir(dstFilePath)
+	for {
+		if _, err = os.Stat(dstDir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		perm := os.FileMode(0755)
+		if srcInfo, err := os.Stat(srcDir); err == nil && srcInfo.IsDir() {
+			perm = srcInfo.Mode().Perm()
+		}
+		missing = append(missing, dirPair{dst: dstDir, perm: perm})
+		if parent := filepath.Dir(dstDir); parent != dstDir {
+			dstDir, srcDir = parent, filepath.Dir(srcDir)
+		} else {
+			break
+		}
+	}
+
+	// Directories are kept writable until the file is in place, then get their final permission bits.
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err = os.Mkdir(missing[i].dst, 0700); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	if err = CopyPreserveTimes(srcFilePath, dstFilePath); err != nil {
+		return err
+	}
+	if err = os.Chmod(dstFilePath, info.Mode()); err != nil {
+		return err
+	}
+	for _, dir := range missing {
+		if err = os.Chmod(dir.dst, dir.perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyRangeInto writes length bytes of srcFilePath starting at srcOffset into dstFilePath at dstOffset,
+// leaving the other bytes of dstFilePath intact. dstFilePath is created if not exists, and extended if needed.
+// It fails with io.ErrUnexpectedEOF, wrapped in a *os.PathError, if the source has less than length bytes at srcOffset.
+func CopyRangeInto(srcFilePath string, srcOffset int64, length int64, dstFilePath string, dstOffset int64) error {
+	return DefaultFS.CopyRangeInto(srcFilePath, srcOffset, length, dstFilePath, dstOffset)
+}
+
+// CopyRangeInto is like the package-level CopyRangeInto but operates on f.
+func (f *FS) CopyRangeInto(srcFilePath string, srcOffset int64, length int64, dstFilePath string, dstOffset int64) (err error) {
+	srcFile, err := f.fs.Open(srcFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := srcFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	dstFile, err := f.fs.OpenFile(dstFilePath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := dstFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if _, err = dstFile.Seek(dstOffset, io.SeekStart); err != nil {
+		return err
+	}
+	n, err := io.Copy(dstFile, io.NewSectionReader(srcFile, srcOffset, length))
+	if err != nil {
+		return err
+	}
+	if n < length {
+		return &os.PathError{Op: "read", Path: srcFilePath, Err: io.ErrUnexpectedEOF}
+	}
+	return nil
+}
+
+// CopyDeadline copies file from srcFilePath to dstFilePath, giving up once deadline has passed
+// with an error wrapping os.ErrDeadlineExceeded. The deadline is checked between chunks of the copy.
+// The destination is written atomically, so an aborted copy leaves no partial file behind.
+func CopyDeadline(srcFilePath string, dstFilePath string, deadline time.Time) error {
+	return DefaultFS.CopyDeadline(srcFilePath, dstFilePath, deadline)
+}
+
+// CopyDeadline is like the package-level CopyDeadline but operates on f.
+func (f *FS) CopyDeadline(srcFilePath string, dstFilePath string, deadline time.Time) (err error) {
+	srcFile, err := f.fs.Open(srcFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := srcFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	r := &deadlineReader{r: srcFile, path: srcFilePath, deadline: deadline}
+	return f.writeAtomic(dstFilePath, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+// deadlineReader fails reads of r once deadline has passed.
+type deadlineReader struct {
+	r        io.Reader
+	path     string
+	deadline time.Time
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	if !time.Now().Before(d.deadline) {
+		return 0, &os.PathError{Op: "copy", Path: d.path, Err: os.ErrDeadlineExceeded}
+	}
+	return d.r.Read(p)
+}
+
+// Read whole content string of a file.
+func Read(filePath string) (string, error) {
+	return DefaultFS.Read(filePath)
+}
+
+// Read is like the package-level Read but operates on f.
+func (f *FS) Read(filePath string) (content string, err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	return ReadAllFrom(file)
+}
+
+// ReadAllFrom reads whole content string of r.
+func ReadAllFrom(r io.Reader) (string, error) {
+	bytes, err := ioutil.ReadAll(r)
+
+	return string(bytes), err
+}
+
+// ReadRetry reads whole content string of a file like Read, retrying up to attempts times
+// with delay in between when reading fails with a transient I/O error, as happens on flaky network mounts.
+// Permanent errors, such as a missing file or a denied permission, are returned right away.
+func ReadRetry(filePath string, attempts int, delay time.Duration) (string, error) {
+	return DefaultFS.ReadRetry(filePath, attempts, delay)
+}
+
+// ReadRetry is like the package-level ReadRetry but operates on f.
+func (f *FS) ReadRetry(filePath string, attempts int, delay time.Duration) (content string, err error) {
+	for i := 0; i == 0 || i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+		}
+		if content, err = f.Read(filePath); err == nil || !isTransientReadErr(err) {
+			return content, err
+		}
+	}
+	return "", err
+}
+
+// isTransientReadErr reports whether a read error may go away on retry.
+func isTransientReadErr(err error) bool {
+	return errors.Is(err, syscall.EIO) ||
+		errors.Is(err, syscall.ESTALE) ||
+		errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.EINTR)
+}
+
+// ReadTimeout reads whole content string of a file like Read, but gives up after timeout,
+// returning an error wrapping os.ErrDeadlineExceeded.
+// A read that really hangs, e.g. on a dead network mount, can't be interrupted:
+// its goroutine is abandoned and stays blocked until the read returns, if ever.
+func ReadTimeout(filePath string, timeout time.Duration) (string, error) {
+	return DefaultFS.ReadTimeout(filePath, timeout)
+}
+
+// ReadTimeout is like the package-level ReadTimeout but operates on f.
+func (f *FS) ReadTimeout(filePath string, timeout time.Duration) (string, error) {
+	type result struct {
+		content string
+		err     error
+	}
+	// Buffered, so an abandoned read can still complete and let its goroutine exit.
+	done := make(chan result, 1)
+	go func() {
+		content, err := f.Read(filePath)
+		done <- result{content, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.content, r.err
+	case <-timer.C:
+		return "", &os.PathError{Op: "read", Path: filePath, Err: os.ErrDeadlineExceeded}
+	}
+}
+
+var readBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// ReadPooled reads whole content of a file into a buffer taken from a shared pool,
+// which saves allocations for callers reading many files.
+// The caller must call release once done with the content; the slice must not be used afterwards.
+func ReadPooled(filePath string) ([]byte, func(), error) {
+	return DefaultFS.ReadPooled(filePath)
+}
+
+// ReadPooled is like the package-level ReadPooled but operates on f.
+func (f *FS) ReadPooled(filePath string) (content []byte, release func(), err error) {
+	file, err := f.fs.Open(filePath)
 	if err != nil {
-		return count, err
+		return nil, nil, err
 	}
 
 	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
 			err = closeErr
 		}
+		if err != nil && release != nil {
+			release()
+			content, release = nil, nil
+		}
 	}()
 
-	br := bufio.NewReader(file)
-	for {
-		if _, _, err = br.ReadLine(); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return 0, err
-		}
-		count++
+	buf := readBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	release = func() {
+		readBufferPool.Put(buf)
 	}
-	return count, nil
+
+	if _, err = buf.ReadFrom(file); err != nil {
+		return nil, release, err
+	}
+	return buf.Bytes(), release, nil
 }
 
-// Copy file from srcFilePath to dstFilePath.
-func Copy(srcFilePath string, dstFilePath string) (err error) {
-	srcFile, err := os.Open(srcFilePath)
+// ErrFileTooLarge is returned by ReadLimit, wrapped in a *os.PathError,
+// when a file is bigger than the allowed size.
+var ErrFileTooLarge = errors.New("file too large")
+
+// ReadLimit reads whole content string of a file, refusing files larger than maxBytes.
+// The size is checked before reading and enforced again while reading, in case the file grows in between.
+func ReadLimit(filePath string, maxBytes int64) (string, error) {
+	return DefaultFS.ReadLimit(filePath, maxBytes)
+}
+
+// ReadLimit is like the package-level ReadLimit but operates on f.
+func (f *FS) ReadLimit(filePath string, maxBytes int64) (content string, err error) {
+	file, err := f.fs.Open(filePath)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > maxBytes {
+		return "", &os.PathError{Op: "read", Path: filePath, Err: ErrFileTooLarge}
 	}
-	r := bufio.NewReader(srcFile)
 
-	dstFile, err := os.OpenFile(dstFilePath, os.O_WRONLY|os.O_CREATE, 0644)
+	bytes, err := ioutil.ReadAll(io.LimitReader(file, maxBytes+1))
 	if err != nil {
-		return err
+		return "", err
 	}
-	w := bufio.NewWriter(dstFile)
+	if int64(len(bytes)) > maxBytes {
+		return "", &os.PathError{Op: "read", Path: filePath, Err: ErrFileTooLarge}
+	}
+	return string(bytes), nil
+}
 
-	_, err = io.Copy(w, r)
+// ReadHeadTail reads the first headBytes and the last tailBytes of a file, without reading what lies in between.
+// When the file is smaller than headBytes+tailBytes, the two parts don't overlap: tail holds only the bytes
+// following head, so it is shorter than tailBytes, or empty.
+// It fails with io.ErrUnexpectedEOF, wrapped in a *os.PathError, if the file shrinks while being read.
+func ReadHeadTail(filePath string, headBytes int, tailBytes int) (head []byte, tail []byte, err error) {
+	return DefaultFS.ReadHeadTail(filePath, headBytes, tailBytes)
+}
 
-	defer func() {
-		closeSrcFileErr := srcFile.Close()
-		closeDstFileErr := dstFile.Close()
+// ReadHeadTail is like the package-level ReadHeadTail but operates on f.
+func (f *FS) ReadHeadTail(filePath string, headBytes int, tailBytes int) (head []byte, tail []byte, err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		if closeSrcFileErr != nil || closeDstFileErr != nil {
-			err = fmt.Errorf("error closeSrcFileErr: %v, closeDstFileErr: %v", closeSrcFileErr, closeDstFileErr)
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
 		}
 	}()
-	return err
-}
 
-// Read whole content string of a file.
-func Read(filePath string) (string, error) {
-	bytes, err := ioutil.ReadFile(filePath)
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
 
-	return string(bytes), err
+	headLen := int64(headBytes)
+	if headLen < 0 {
+		headLen = 0
+	}
+	if headLen > size {
+		headLen = size
+	}
+	tailLen := int64(tailBytes)
+	if tailLen < 0 {
+		tailLen = 0
+	}
+	if tailLen > size-headLen {
+		tailLen = size - headLen
+	}
+
+	head = make([]byte, headLen)
+	if err = readAtFull(file, head, 0); err != nil {
+		return nil, nil, err
+	}
+	tail = make([]byte, tailLen)
+	if err = readAtFull(file, tail, size-tailLen); err != nil {
+		return nil, nil, err
+	}
+	return head, tail, nil
 }
 
 // Write string data into file.
 // It creates file if not exists, and overwrite whole content in case file already exists.
 func Write(filePath string, data string) error {
-	return ioutil.WriteFile(filePath, []byte(data), 0644)
+	return DefaultFS.Write(filePath, data)
+}
+
+// Write is like the package-level Write but operates on f.
+func (f *FS) Write(filePath string, data string) (err error) {
+	file, err := f.fs.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	return WriteAllTo(file, data)
+}
+
+// WriteAllTo writes string data into w.
+func WriteAllTo(w io.Writer, data string) error {
+	_, err := io.WriteString(w, data)
+	return err
+}
+
+// WriteIfChanged writes string data into file like Write, unless the file already has exactly that content,
+// in which case it is left untouched and keeps its modification time. It returns whether the file was written.
+func WriteIfChanged(filePath string, data string) (bool, error) {
+	return DefaultFS.WriteIfChanged(filePath, data)
+}
+
+// WriteIfChanged is like the package-level WriteIfChanged but operates on f.
+func (f *FS) WriteIfChanged(filePath string, data string) (changed bool, err error) {
+	same, err := f.hasContent(filePath, data)
+	if err != nil || same {
+		return false, err
+	}
+	if err = f.Write(filePath, data); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// hasContent checks if given file exists with exactly data as content.
+func (f *FS) hasContent(filePath string, data string) (same bool, err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != int64(len(data)) {
+		return false, nil
+	}
+	return sameReaderContent(file, strings.NewReader(data))
+}
+
+// CreateExclusive creates a new file and returns it opened for reading and writing.
+// It fails with an error wrapping os.ErrExist in case filePath already exists,
+// which makes it suitable for lock files.
+func CreateExclusive(filePath string) (*os.File, error) {
+	return os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+}
+
+// WriteMkdir writes string data into file like Write,
+// creating the parent directories of filePath first if not exist.
+func WriteMkdir(filePath string, data string) error {
+	return DefaultFS.WriteMkdir(filePath, data)
+}
+
+// WriteMkdir is like the package-level WriteMkdir but operates on f.
+func (f *FS) WriteMkdir(filePath string, data string) error {
+	if err := f.EnsureParentDir(filePath); err != nil {
+		return err
+	}
+	return f.Write(filePath, data)
 }
 
 // Exists checks if a file or directory exists.
 func Exists(filePath string) bool {
-	if _, err := os.Stat(filePath); err != nil {
+	return DefaultFS.Exists(filePath)
+}
+
+// Exists is like the package-level Exists but operates on f.
+func (f *FS) Exists(filePath string) bool {
+	if _, err := f.fs.Stat(filePath); err != nil {
 		return os.IsExist(err)
 	}
 	return true
 }
 
+// Age returns the time elapsed since a file or directory was last modified.
+func Age(filePath string) (time.Duration, error) {
+	return DefaultFS.Age(filePath)
+}
+
+// Age is like the package-level Age but operates on f.
+func (f *FS) Age(filePath string) (time.Duration, error) {
+	info, err := f.fs.Stat(filePath)
+	if err != nil {
+		return 0, err
+	}
+	return time.Since(info.ModTime()), nil
+}
+
+// OlderThan checks if a file or directory was last modified more than d ago.
+func OlderThan(filePath string, d time.Duration) (bool, error) {
+	return DefaultFS.OlderThan(filePath, d)
+}
+
+// OlderThan is like the package-level OlderThan but operates on f.
+func (f *FS) OlderThan(filePath string, d time.Duration) (bool, error) {
+	age, err := f.Age(filePath)
+	if err != nil {
+		return false, err
+	}
+	return age > d, nil
+}
+
+// textSniffLen is the number of leading bytes IsTextFile and DetectEncoding inspect.
+const textSniffLen = 8 << 10
+
+// IsTextFile guesses if a file holds text rather than binary data, by checking its first 8KB
+// contain no NUL byte and are valid UTF-8, similar to git. An empty file is considered text.
+func IsTextFile(filePath string) (bool, error) {
+	return DefaultFS.IsTextFile(filePath)
+}
+
+// IsTextFile is like the package-level IsTextFile but operates on f.
+func (f *FS) IsTextFile(filePath string) (bool, error) {
+	head, err := f.sniff(filePath)
+	if err != nil {
+		return false, err
+	}
+	return bytes.IndexByte(head, 0) < 0 && utf8.Valid(head), nil
+}
+
+// sniff returns the first textSniffLen bytes of a file, for guessing its kind of content.
+// When the file is longer, a multi-byte UTF-8 character cut at the end is dropped.
+func (f *FS) sniff(filePath string) (head []byte, err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	buf := make([]byte, textSniffLen)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	if n == textSniffLen {
+		for i := n - 1; i >= 0 && i >= n-utf8.UTFMax; i-- {
+			if utf8.RuneStart(buf[i]) {
+				if !utf8.FullRune(buf[i:]) {
+					buf = buf[:i]
+				}
+				break
+			}
+		}
+	}
+	return buf, nil
+}
+
 // IsReadable checks if a file or directory can be read.
 func IsReadable(filePath string) bool {
 	return syscall.Access(filePath, unix.R_OK) == nil
@@ -94,28 +1111,117 @@ func IsReadable(filePath string) bool {
 
 // Rename a file or directory.
 func Rename(oldFilePath string, newFilePath string) error {
-	return os.Rename(oldFilePath, newFilePath)
+	return DefaultFS.Rename(oldFilePath, newFilePath)
+}
+
+// Rename is like the package-level Rename but operates on f.
+func (f *FS) Rename(oldFilePath string, newFilePath string) error {
+	return f.fs.Rename(oldFilePath, newFilePath)
+}
+
+// Link creates newFilePath as a hard link to oldFilePath, creating parent directories of newFilePath if not exist.
+// Hard links can not cross filesystems, so both paths must be on the same mount.
+func Link(oldFilePath string, newFilePath string) error {
+	if err := EnsureParentDir(newFilePath); err != nil {
+		return err
+	}
+	return os.Link(oldFilePath, newFilePath)
+}
+
+// Symlink creates newFilePath as a symbolic link to oldFilePath, creating parent directories of newFilePath if not exist.
+// A relative oldFilePath is resolved from the directory of newFilePath when the link is followed.
+func Symlink(oldFilePath string, newFilePath string) error {
+	if err := EnsureParentDir(newFilePath); err != nil {
+		return err
+	}
+	return os.Symlink(oldFilePath, newFilePath)
 }
 
 // Remove removes given filePath and any children it contains.
 func Remove(filePath string) error {
-	return os.RemoveAll(filePath)
+	return DefaultFS.Remove(filePath)
+}
+
+// Remove is like the package-level Remove but operates on f.
+func (f *FS) Remove(filePath string) error {
+	return f.fs.RemoveAll(filePath)
+}
+
+// RemoveRetry removes given filePath like Remove, retrying up to attempts times
+// with delay in between when removal fails with a transient error, as happens on network mounts.
+func RemoveRetry(filePath string, attempts int, delay time.Duration) error {
+	return DefaultFS.RemoveRetry(filePath, attempts, delay)
+}
+
+// RemoveRetry is like the package-level RemoveRetry but operates on f.
+func (f *FS) RemoveRetry(filePath string, attempts int, delay time.Duration) error {
+	return removeRetry(f.fs.RemoveAll, filePath, attempts, delay)
+}
+
+func removeRetry(removeAll func(string) error, filePath string, attempts int, delay time.Duration) (err error) {
+	for i := 0; i == 0 || i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+		}
+		if err = removeAll(filePath); err == nil || !isTransientRemoveErr(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// isTransientRemoveErr reports whether a removal error may go away on retry,
+// e.g. a file still held open on NFS or SMB.
+func isTransientRemoveErr(err error) bool {
+	return errors.Is(err, syscall.EBUSY) ||
+		errors.Is(err, syscall.ENOTEMPTY) ||
+		errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.ETXTBSY)
 }
 
 // MakeDir creates a directory recursively.
 func MakeDir(dirPath string) error {
-	return os.MkdirAll(dirPath, 0755)
+	return DefaultFS.MakeDir(dirPath)
+}
+
+// MakeDir is like the package-level MakeDir but operates on f.
+func (f *FS) MakeDir(dirPath string) error {
+	return f.fs.MkdirAll(dirPath, 0755)
+}
+
+// EnsureParentDir creates the parent directory of filePath like MakeDir, doing nothing if it already exists.
+func EnsureParentDir(filePath string) error {
+	return DefaultFS.EnsureParentDir(filePath)
+}
+
+// EnsureParentDir is like the package-level EnsureParentDir but operates on f.
+func (f *FS) EnsureParentDir(filePath string) error {
+	return f.MakeDir(filepath.Dir(filePath))
+}
+
+// MakeFIFO creates a named pipe with given permission bits.
+// It fails with an error wrapping os.ErrExist in case filePath already exists.
+func MakeFIFO(filePath string, perm os.FileMode) error {
+	if err := unix.Mkfifo(filePath, uint32(perm.Perm())); err != nil {
+		return &os.PathError{Op: "mkfifo", Path: filePath, Err: err}
+	}
+	return nil
 }
 
 // ClearDir removes all files in a directory.
-func ClearDir(dirPath string) (err error) {
-	dir, err := os.Open(dirPath)
+func ClearDir(dirPath string) error {
+	return DefaultFS.ClearDir(dirPath)
+}
+
+// ClearDir is like the package-level ClearDir but operates on f.
+func (f *FS) ClearDir(dirPath string) (err error) {
+	dir, err := f.fs.Open(dirPath)
 	if err != nil {
 		return err
 	}
 
 	defer func() {
-		if closeErr := dir.Close(); closeErr != nil {
+		if closeErr := dir.Close(); closeErr != nil && err == nil {
 			err = closeErr
 		}
 	}()
@@ -125,22 +1231,63 @@ func ClearDir(dirPath string) (err error) {
 		return err
 	}
 	for _, name := range names {
-		if err = os.RemoveAll(filepath.Join(dirPath, name)); err != nil {
+		if err = f.fs.RemoveAll(filepath.Join(dirPath, name)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// IsEmptyDir checks if a directory contains no entries, without listing all of them.
+// It returns an error in case dirPath is not a directory.
+func IsEmptyDir(dirPath string) (bool, error) {
+	return DefaultFS.IsEmptyDir(dirPath)
+}
+
+// IsEmptyDir is like the package-level IsEmptyDir but operates on f.
+func (f *FS) IsEmptyDir(dirPath string) (empty bool, err error) {
+	dir, err := f.fs.Open(dirPath)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if closeErr := dir.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	info, err := dir.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		return false, &os.PathError{Op: "readdirent", Path: dirPath, Err: syscall.ENOTDIR}
+	}
+
+	if _, err = dir.Readdirnames(1); err != nil {
+		if err == io.EOF {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
+
 // GetAllFiles returns all files in a directory.
 // If suffix is not empty, it returns only files of specified suffix.
-func GetAllFiles(dirPath string, suffix string) (filePaths []string, err error) {
-	dir, err := os.Open(dirPath)
+func GetAllFiles(dirPath string, suffix string) ([]string, error) {
+	return DefaultFS.GetAllFiles(dirPath, suffix)
+}
+
+// GetAllFiles is like the package-level GetAllFiles but operates on f.
+func (f *FS) GetAllFiles(dirPath string, suffix string) (filePaths []string, err error) {
+	dir, err := f.fs.Open(dirPath)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
-		if closeErr := dir.Close(); closeErr != nil {
+		if closeErr := dir.Close(); closeErr != nil && err == nil {
 			err = closeErr
 		}
 	}()
@@ -162,15 +1309,57 @@ func GetAllFiles(dirPath string, suffix string) (filePaths []string, err error)
 	return filePaths, nil
 }
 
+// readDirBatch is the number of directory entries ForEachEntry reads at once.
+const readDirBatch = 1024
+
+// ForEachEntry calls fn for every entry of a directory, in directory order, stopping at the first error fn returns.
+// Unlike GetAllFiles, entries are read in batches, so memory stays bounded even for huge directories.
+func ForEachEntry(dirPath string, fn func(entry os.DirEntry) error) error {
+	return DefaultFS.ForEachEntry(dirPath, fn)
+}
+
+// ForEachEntry is like the package-level ForEachEntry but operates on f.
+func (f *FS) ForEachEntry(dirPath string, fn func(entry os.DirEntry) error) (err error) {
+	dir, err := f.fs.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := dir.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	for {
+		entries, err := dir.ReadDir(readDirBatch)
+		for _, entry := range entries {
+			if fnErr := fn(entry); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
 // AppendString appends string data to a file.
 // It creates distFile in case not exists, and truncates distFile in case already exists.
-func AppendString(filePath string, data string) (err error) {
-	dstFile, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+func AppendString(filePath string, data string) error {
+	return DefaultFS.AppendString(filePath, data)
+}
+
+// AppendString is like the package-level AppendString but operates on f.
+func (f *FS) AppendString(filePath string, data string) (err error) {
+	dstFile, err := f.fs.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		if closeErr := dstFile.Close(); closeErr != nil {
+		if closeErr := dstFile.Close(); closeErr != nil && err == nil {
 			err = closeErr
 		}
 	}()
@@ -181,3 +1370,114 @@ func AppendString(filePath string, data string) (err error) {
 	}
 	return writer.Flush()
 }
+
+// AppendSync appends string data to a file like AppendString,
+// and flushes the file to stable storage before returning, so appended data survives a crash.
+func AppendSync(filePath string, data string) error {
+	return DefaultFS.AppendSync(filePath, data)
+}
+
+// AppendSync is like the package-level AppendSync but operates on f.
+func (f *FS) AppendSync(filePath string, data string) (err error) {
+	dstFile, err := f.fs.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := dstFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if _, err = io.WriteString(dstFile, data); err != nil {
+		return err
+	}
+	return dstFile.Sync()
+}
+
+// LineEnding is a line ending style used by NormalizeLineEndings.
+type LineEnding int
+
+const (
+	// LF is the "\n" line ending used on Unix.
+	LF LineEnding = iota
+	// CRLF is the "\r\n" line ending used on Windows.
+	CRLF
+)
+
+// NormalizeLineEndings converts all line endings of given file to style, and returns the number of lines changed.
+// The file is replaced atomically, and left untouched in case no line needs to change.
+func NormalizeLineEndings(filePath string, style LineEnding) (int, error) {
+	return DefaultFS.NormalizeLineEndings(filePath, style)
+}
+
+// NormalizeLineEndings is like the package-level NormalizeLineEndings but operates on f.
+func (f *FS) NormalizeLineEndings(filePath string, style LineEnding) (int, error) {
+	content, err := f.Read(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(content))
+	changed := 0
+	for len(content) > 0 {
+		i := strings.IndexByte(content, '\n')
+		if i < 0 {
+			sb.WriteString(content)
+			break
+		}
+		line, hasCR := content[:i], i > 0 && content[i-1] == '\r'
+		if hasCR {
+			line = line[:len(line)-1]
+		}
+		sb.WriteString(line)
+		if style == CRLF {
+			sb.WriteString("\r\n")
+		} else {
+			sb.WriteByte('\n')
+		}
+		if hasCR != (style == CRLF) {
+			changed++
+		}
+		content = content[i+1:]
+	}
+
+	if changed == 0 {
+		return 0, nil
+	}
+	if err = f.writeAtomicString(filePath, sb.String()); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// AppendLineUnique appends line to given file, unless the file already has exactly that line.
+// It creates the file in case not exists, and adds the missing line ending of the last line first if needed.
+// It returns whether line was appended.
+func AppendLineUnique(filePath string, line string) (bool, error) {
+	return DefaultFS.AppendLineUnique(filePath, line)
+}
+
+// AppendLineUnique is like the package-level AppendLineUnique but operates on f.
+func (f *FS) AppendLineUnique(filePath string, line string) (added bool, err error) {
+	content, err := f.Read(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	for _, existing := range splitLines(content) {
+		if trimLineEnding(existing) == line {
+			return false, nil
+		}
+	}
+
+	data := line + "\n"
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		data = "\n" + data
+	}
+	if err = f.AppendString(filePath, data); err != nil {
+		return false, err
+	}
+	return true, nil
+}
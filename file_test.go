@@ -0,0 +1,1396 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, filePath string, data string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, filePath string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReadLimit(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data")
+	writeTestFile(t, filePath, strings.Repeat("x", 100))
+
+	content, err := ReadLimit(filePath, 100)
+	if err != nil {
+		t.Fatalf("ReadLimit under limit: %v", err)
+	}
+	if len(content) != 100 {
+		t.Errorf("ReadLimit under limit read %d bytes, want 100", len(content))
+	}
+
+	_, err = ReadLimit(filePath, 99)
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("ReadLimit over limit: got %v, want ErrFileTooLarge", err)
+	}
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != filePath {
+		t.Errorf("ReadLimit over limit: got %v, want a *os.PathError for %s", err, filePath)
+	}
+}
+
+func TestAgeOlderThan(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data")
+	writeTestFile(t, filePath, "x")
+	backdated := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filePath, backdated, backdated); err != nil {
+		t.Fatal(err)
+	}
+
+	age, err := Age(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if age < 2*time.Hour || age > 2*time.Hour+time.Minute {
+		t.Errorf("Age = %v, want about 2h", age)
+	}
+
+	for _, tt := range []struct {
+		d    time.Duration
+		want bool
+	}{
+		{time.Hour, true},
+		{3 * time.Hour, false},
+	} {
+		older, err := OlderThan(filePath, tt.d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if older != tt.want {
+			t.Errorf("OlderThan(%v) = %v, want %v", tt.d, older, tt.want)
+		}
+	}
+
+	if _, err := Age(filePath + ".missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Age of missing file: got %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestCopyToDir(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath := filepath.Join(dir, "report.txt")
+	writeTestFile(t, srcFilePath, "content")
+	dstDirPath := filepath.Join(dir, "out", "nested")
+
+	dstFilePath, err := CopyToDir(srcFilePath, dstDirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dstDirPath, "report.txt"); dstFilePath != want {
+		t.Errorf("CopyToDir returned %s, want %s", dstFilePath, want)
+	}
+	if got := readTestFile(t, dstFilePath); got != "content" {
+		t.Errorf("copied content = %q, want %q", got, "content")
+	}
+}
+
+func TestCopyNoClobber(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath, dstFilePath := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	writeTestFile(t, srcFilePath, "first")
+
+	if err := CopyNoClobber(srcFilePath, dstFilePath); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, srcFilePath, "second")
+	if err := CopyNoClobber(srcFilePath, dstFilePath); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("second CopyNoClobber: got %v, want os.ErrExist", err)
+	}
+	if got := readTestFile(t, dstFilePath); got != "first" {
+		t.Errorf("destination = %q after refused copy, want %q", got, "first")
+	}
+}
+
+func TestCopyTruncatesLongerDestination(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath, dstFilePath := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	writeTestFile(t, srcFilePath, "short")
+	writeTestFile(t, dstFilePath, "a much longer destination")
+
+	if err := Copy(srcFilePath, dstFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, dstFilePath); got != "short" {
+		t.Errorf("destination = %q, want %q", got, "short")
+	}
+}
+
+func TestCopySmallFile(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath, dstFilePath := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	// Smaller than the bufio.Writer buffer, so it is only written by the final flush.
+	data := strings.Repeat("x", 100)
+	writeTestFile(t, srcFilePath, data)
+
+	if err := Copy(srcFilePath, dstFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, dstFilePath); got != data {
+		t.Errorf("destination has %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestRemoveRetry(t *testing.T) {
+	busy := &os.PathError{Op: "unlinkat", Path: "dir", Err: syscall.EBUSY}
+
+	calls := 0
+	flaky := func(string) error {
+		if calls++; calls <= 2 {
+			return busy
+		}
+		return nil
+	}
+	if err := removeRetry(flaky, "dir", 5, time.Millisecond); err != nil {
+		t.Fatalf("removeRetry: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("remover called %d times, want 3", calls)
+	}
+
+	calls = 0
+	alwaysBusy := func(string) error {
+		calls++
+		return busy
+	}
+	if err := removeRetry(alwaysBusy, "dir", 3, time.Millisecond); !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("removeRetry giving up: got %v, want EBUSY", err)
+	}
+	if calls != 3 {
+		t.Errorf("remover called %d times, want 3 attempts", calls)
+	}
+
+	calls = 0
+	denied := func(string) error {
+		calls++
+		return &os.PathError{Op: "unlinkat", Path: "dir", Err: syscall.EACCES}
+	}
+	if err := removeRetry(denied, "dir", 3, time.Millisecond); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("removeRetry permanent error: got %v, want os.ErrPermission", err)
+	}
+	if calls != 1 {
+		t.Errorf("remover called %d times on a permanent error, want 1", calls)
+	}
+}
+
+func TestErrorsCarryPath(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	srcFilePath := filepath.Join(dir, "src")
+	writeTestFile(t, srcFilePath, "x")
+
+	assertPathError := func(name string, err error, target error, path string) {
+		t.Helper()
+		if !errors.Is(err, target) {
+			t.Errorf("%s: got %v, want errors.Is %v", name, err, target)
+		}
+		var pathErr *os.PathError
+		if !errors.As(err, &pathErr) || pathErr.Path != path {
+			t.Errorf("%s: got %v, want a *os.PathError for %s", name, err, path)
+		}
+	}
+
+	_, err := Read(missing)
+	assertPathError("Read", err, os.ErrNotExist, missing)
+
+	err = Copy(missing, filepath.Join(dir, "dst"))
+	assertPathError("Copy from missing source", err, os.ErrNotExist, missing)
+
+	dstFilePath := filepath.Join(missing, "dst")
+	err = Copy(srcFilePath, dstFilePath)
+	assertPathError("Copy into missing directory", err, os.ErrNotExist, dstFilePath)
+
+	// A regular file can't have children, so removing one fails.
+	child := filepath.Join(srcFilePath, "child")
+	err = Remove(child)
+	assertPathError("Remove", err, syscall.ENOTDIR, child)
+}
+
+// syncCountFS counts Sync calls on files it opens.
+type syncCountFS struct {
+	*memFS
+	syncs int
+}
+
+func (s *syncCountFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	file, err := s.memFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &syncCountFile{File: file, fs: s}, nil
+}
+
+type syncCountFile struct {
+	File
+	fs *syncCountFS
+}
+
+func (f *syncCountFile) Sync() error {
+	f.fs.syncs++
+	return f.File.Sync()
+}
+
+func TestAppendSync(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "log")
+	for _, data := range []string{"one\n", "two\n"} {
+		if err := AppendSync(filePath, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readTestFile(t, filePath); got != "one\ntwo\n" {
+		t.Errorf("content = %q, want %q", got, "one\ntwo\n")
+	}
+
+	counting := &syncCountFS{memFS: newMemFS()}
+	if err := NewFS(counting).AppendSync("/log", "one\n"); err != nil {
+		t.Fatal(err)
+	}
+	if counting.syncs != 1 {
+		t.Errorf("AppendSync synced %d times, want 1", counting.syncs)
+	}
+}
+
+func BenchmarkAppendString(b *testing.B) {
+	filePath := filepath.Join(b.TempDir(), "log")
+	for i := 0; i < b.N; i++ {
+		if err := AppendString(filePath, "a log record\n"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendSync(b *testing.B) {
+	filePath := filepath.Join(b.TempDir(), "log")
+	for i := 0; i < b.N; i++ {
+		if err := AppendSync(filePath, "a log record\n"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReadPooled(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data")
+	data := strings.Repeat("pooled content\n", 1000)
+	writeTestFile(t, filePath, data)
+
+	for i := 0; i < 3; i++ {
+		content, release, err := ReadPooled(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != data {
+			t.Fatalf("ReadPooled read %d bytes, want %d", len(content), len(data))
+		}
+		release()
+	}
+
+	if _, _, err := ReadPooled(filePath + ".missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadPooled of missing file: got %v, want os.ErrNotExist", err)
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	filePath := filepath.Join(b.TempDir(), "data")
+	if err := ioutil.WriteFile(filePath, bytes.Repeat([]byte("x"), 16<<10), 0644); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Read(filePath); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadPooled(b *testing.B) {
+	filePath := filepath.Join(b.TempDir(), "data")
+	if err := ioutil.WriteFile(filePath, bytes.Repeat([]byte("x"), 16<<10), 0644); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, release, err := ReadPooled(filePath)
+		if err != nil {
+			b.Fatal(err)
+		}
+		release()
+	}
+}
+
+func TestReadPooledAllocatesLess(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data")
+	writeTestFile(t, filePath, strings.Repeat("x", 16<<10))
+
+	readAllocs := testing.AllocsPerRun(100, func() {
+		Read(filePath)
+	})
+	pooledAllocs := testing.AllocsPerRun(100, func() {
+		if _, release, err := ReadPooled(filePath); err == nil {
+			release()
+		}
+	})
+	if pooledAllocs >= readAllocs {
+		t.Errorf("ReadPooled made %v allocations per read, Read %v; want fewer", pooledAllocs, readAllocs)
+	}
+}
+
+func TestWriteMkdirCopyMkdir(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "a", "b", "c", "file.txt")
+	if err := WriteMkdir(filePath, "deep"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filePath); got != "deep" {
+		t.Errorf("WriteMkdir content = %q, want %q", got, "deep")
+	}
+
+	dstFilePath := filepath.Join(dir, "x", "y", "z", "copy.txt")
+	if err := CopyMkdir(filePath, dstFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, dstFilePath); got != "deep" {
+		t.Errorf("CopyMkdir content = %q, want %q", got, "deep")
+	}
+}
+
+func TestGrepCount(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "app.log")
+	writeTestFile(t, filePath, "INFO start\nERROR disk\nINFO tick\nERROR net\r\nERROR cpu\n")
+	re := regexp.MustCompile(`^ERROR`)
+
+	count, matches, err := GrepCount(filePath, re, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+	want := []GrepMatch{{Line: 2, Text: "ERROR disk"}, {Line: 4, Text: "ERROR net"}}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("matches = %v, want %v", matches, want)
+	}
+
+	count, matches, err = GrepCount(filePath, re, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || len(matches) != 3 {
+		t.Errorf("uncapped: count = %d with %d matches, want 3 and 3", count, len(matches))
+	}
+}
+
+func TestReadDelimited(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name  string
+		data  string
+		delim byte
+		want  []string
+	}{
+		{"nul", "a\x00bc\x00\x00d\x00", 0, []string{"a", "bc", "", "d"}},
+		{"no trailing delimiter", "a;b;c", ';', []string{"a", "b", "c"}},
+		{"empty", "", ';', nil},
+	} {
+		filePath := filepath.Join(dir, tt.name)
+		writeTestFile(t, filePath, tt.data)
+
+		var got []string
+		err := ReadDelimited(filePath, tt.delim, func(record []byte) error {
+			got = append(got, string(record))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: records = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeLineEndings(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "text")
+	writeTestFile(t, filePath, "one\r\ntwo\r\nthree\nfour")
+
+	changed, err := NormalizeLineEndings(filePath, LF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 2 {
+		t.Errorf("to LF changed %d lines, want 2", changed)
+	}
+	if got := readTestFile(t, filePath); got != "one\ntwo\nthree\nfour" {
+		t.Errorf("to LF content = %q", got)
+	}
+
+	changed, err = NormalizeLineEndings(filePath, CRLF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 3 {
+		t.Errorf("to CRLF changed %d lines, want 3", changed)
+	}
+	if got := readTestFile(t, filePath); got != "one\r\ntwo\r\nthree\r\nfour" {
+		t.Errorf("to CRLF content = %q", got)
+	}
+
+	if changed, err = NormalizeLineEndings(filePath, CRLF); err != nil || changed != 0 {
+		t.Errorf("converting again = %d, %v; want 0, nil", changed, err)
+	}
+}
+
+func TestIsEmptyDir(t *testing.T) {
+	dir := t.TempDir()
+	emptyDir := filepath.Join(dir, "empty")
+	if err := os.Mkdir(emptyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	filePath := filepath.Join(dir, "file")
+	writeTestFile(t, filePath, "x")
+
+	if empty, err := IsEmptyDir(emptyDir); err != nil || !empty {
+		t.Errorf("IsEmptyDir(empty) = %v, %v; want true, nil", empty, err)
+	}
+	if empty, err := IsEmptyDir(dir); err != nil || empty {
+		t.Errorf("IsEmptyDir(non-empty) = %v, %v; want false, nil", empty, err)
+	}
+	if _, err := IsEmptyDir(filePath); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("IsEmptyDir(file): got %v, want ENOTDIR", err)
+	}
+}
+
+func TestLinkSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	writeTestFile(t, target, "content")
+
+	hardLink := filepath.Join(dir, "hard", "nested", "link")
+	if err := Link(target, hardLink); err != nil {
+		t.Fatal(err)
+	}
+	targetInfo, err := os.Lstat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkInfo, err := os.Lstat(hardLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(targetInfo, linkInfo) {
+		t.Error("hard link is not the same file as its target")
+	}
+
+	symlink := filepath.Join(dir, "soft", "nested", "link")
+	if err := Symlink(target, symlink); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(symlink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink mode = %v, want a symlink", info.Mode())
+	}
+	if dest, err := os.Readlink(symlink); err != nil || dest != target {
+		t.Errorf("Readlink = %q, %v; want %q", dest, err, target)
+	}
+}
+
+func TestCopyPreserveTimes(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath, dstFilePath := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	writeTestFile(t, srcFilePath, "content")
+	mtime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(srcFilePath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CopyPreserveTimes(srcFilePath, dstFilePath); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dstFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := info.ModTime().Sub(mtime); diff < -time.Millisecond || diff > time.Millisecond {
+		t.Errorf("destination mtime = %v, want %v", info.ModTime(), mtime)
+	}
+	if got := readTestFile(t, dstFilePath); got != "content" {
+		t.Errorf("destination content = %q", got)
+	}
+}
+
+func TestIsTextFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"utf8", "plain text\nwith lines\n", true},
+		{"empty", "", true},
+		{"nul", "text\x00more", false},
+		{"high code points", "日本語 emoji 😀 and ünïcödé", true},
+		{"invalid utf8", "bad \xff\xfe bytes", false},
+		// A multi-byte rune cut by the 8KB sniff limit is still text.
+		{"rune at sniff limit", strings.Repeat("a", textSniffLen-1) + "日本", true},
+	} {
+		filePath := filepath.Join(dir, tt.name)
+		writeTestFile(t, filePath, tt.data)
+		got, err := IsTextFile(filePath)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("IsTextFile(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadLineAt(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "lines")
+	writeTestFile(t, filePath, "first\nsecond\r\nthird\nlast")
+
+	for _, tt := range []struct {
+		lineNum int
+		want    string
+	}{
+		{1, "first"},
+		{2, "second"},
+		{4, "last"},
+	} {
+		line, err := ReadLineAt(filePath, tt.lineNum)
+		if err != nil {
+			t.Fatalf("ReadLineAt(%d): %v", tt.lineNum, err)
+		}
+		if line != tt.want {
+			t.Errorf("ReadLineAt(%d) = %q, want %q", tt.lineNum, line, tt.want)
+		}
+	}
+
+	for _, lineNum := range []int{0, 5} {
+		if _, err := ReadLineAt(filePath, lineNum); !errors.Is(err, ErrLineNotFound) {
+			t.Errorf("ReadLineAt(%d): got %v, want ErrLineNotFound", lineNum, err)
+		}
+	}
+}
+
+func TestAppendLineUnique(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "hosts")
+	writeTestFile(t, filePath, "127.0.0.1 localhost\r\n::1 localhost")
+
+	for _, tt := range []struct {
+		line  string
+		added bool
+		want  string
+	}{
+		{"10.0.0.1 db", true, "127.0.0.1 localhost\r\n::1 localhost\n10.0.0.1 db\n"},
+		{"10.0.0.1 db", false, "127.0.0.1 localhost\r\n::1 localhost\n10.0.0.1 db\n"},
+		{"127.0.0.1 localhost", false, "127.0.0.1 localhost\r\n::1 localhost\n10.0.0.1 db\n"},
+		{"", true, "127.0.0.1 localhost\r\n::1 localhost\n10.0.0.1 db\n\n"},
+		{"", false, "127.0.0.1 localhost\r\n::1 localhost\n10.0.0.1 db\n\n"},
+	} {
+		added, err := AppendLineUnique(filePath, tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != tt.added {
+			t.Errorf("AppendLineUnique(%q) = %v, want %v", tt.line, added, tt.added)
+		}
+		if got := readTestFile(t, filePath); got != tt.want {
+			t.Errorf("after AppendLineUnique(%q) content = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestAppendLineUniqueMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, line := range []string{"entry", ""} {
+		filePath := filepath.Join(dir, "missing"+line)
+		added, err := AppendLineUnique(filePath, line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !added {
+			t.Errorf("AppendLineUnique(missing, %q) = false, want true", line)
+		}
+		if got := readTestFile(t, filePath); got != line+"\n" {
+			t.Errorf("created content = %q, want %q", got, line+"\n")
+		}
+	}
+}
+
+func TestCreateExclusive(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "job.lock")
+	file, err := CreateExclusive(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString("pid"); err != nil {
+		t.Errorf("writing the created file: %v", err)
+	}
+
+	if second, err := CreateExclusive(filePath); !errors.Is(err, os.ErrExist) {
+		if second != nil {
+			second.Close()
+		}
+		t.Errorf("second CreateExclusive: got %v, want os.ErrExist", err)
+	}
+}
+
+// blockingFS opens files whose reads block until release is closed, like on a dead network mount.
+type blockingFS struct {
+	*memFS
+	release chan struct{}
+}
+
+func (b *blockingFS) Open(name string) (File, error) {
+	file, err := b.memFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &blockingFile{File: file, release: b.release}, nil
+}
+
+type blockingFile struct {
+	File
+	release chan struct{}
+}
+
+func (f *blockingFile) Read(p []byte) (int, error) {
+	<-f.release
+	return f.File.Read(p)
+}
+
+func TestReadTimeout(t *testing.T) {
+	mem := newMemFS()
+	if err := NewFS(mem).Write("/slow", "content"); err != nil {
+		t.Fatal(err)
+	}
+	blocking := &blockingFS{memFS: mem, release: make(chan struct{})}
+	defer close(blocking.release)
+
+	start := time.Now()
+	_, err := NewFS(blocking).ReadTimeout("/slow", 20*time.Millisecond)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("ReadTimeout of a hanging read: got %v, want os.ErrDeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ReadTimeout returned after %v, want about the timeout", elapsed)
+	}
+
+	content, err := NewFS(mem).ReadTimeout("/slow", time.Second)
+	if err != nil || content != "content" {
+		t.Errorf("ReadTimeout of a fast read = %q, %v; want %q, nil", content, err, "content")
+	}
+}
+
+func TestCopyReport(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath, dstFilePath := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	writeTestFile(t, srcFilePath, "output")
+
+	result, err := CopyReport(srcFilePath, dstFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (CopyResult{BytesCopied: 6, DstCreated: true}); result != want {
+		t.Errorf("new destination: %+v, want %+v", result, want)
+	}
+
+	writeTestFile(t, dstFilePath, "different")
+	result, err = CopyReport(srcFilePath, dstFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (CopyResult{BytesCopied: 6, DstModified: true}); result != want {
+		t.Errorf("different destination: %+v, want %+v", result, want)
+	}
+	if got := readTestFile(t, dstFilePath); got != "output" {
+		t.Errorf("overwritten destination = %q", got)
+	}
+
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(dstFilePath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	result, err = CopyReport(srcFilePath, dstFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (CopyResult{}) {
+		t.Errorf("identical destination: %+v, want nothing copied", result)
+	}
+	if info, err := os.Stat(dstFilePath); err != nil || !info.ModTime().Equal(mtime) {
+		t.Errorf("identical destination was rewritten")
+	}
+}
+
+func TestCountLineReaderGzip(t *testing.T) {
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(strings.Repeat("log line\n", 250) + "unterminated"))
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	gr, err := gzip.NewReader(&gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := CountLineReader(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 251 {
+		t.Errorf("CountLineReader = %d, want 251", count)
+	}
+}
+
+func TestReaderWriterCores(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteAllTo(&buf, "one\ntwo\nthree\n"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "one\ntwo\nthree\n" {
+		t.Errorf("WriteAllTo wrote %q", buf.String())
+	}
+
+	count, err := CountLineReader(bytes.NewReader(buf.Bytes()))
+	if err != nil || count != 3 {
+		t.Errorf("CountLineReader = %d, %v; want 3, nil", count, err)
+	}
+
+	content, err := ReadAllFrom(&buf)
+	if err != nil || content != "one\ntwo\nthree\n" {
+		t.Errorf("ReadAllFrom = %q, %v", content, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("ReadAllFrom left %d bytes unread", buf.Len())
+	}
+}
+
+func TestCopyMeta(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath, dstFilePath := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	writeTestFile(t, srcFilePath, "source")
+	writeTestFile(t, dstFilePath, "generated")
+	if err := os.Chmod(srcFilePath, 0750); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(srcFilePath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CopyMeta(srcFilePath, dstFilePath); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dstFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("destination mode = %v, want %v", info.Mode().Perm(), os.FileMode(0750))
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("destination mtime = %v, want %v", info.ModTime(), mtime)
+	}
+	if got := readTestFile(t, dstFilePath); got != "generated" {
+		t.Errorf("destination content changed to %q", got)
+	}
+}
+
+func TestWriteIfChanged(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config")
+	if changed, err := WriteIfChanged(filePath, "a=1\n"); err != nil || !changed {
+		t.Fatalf("WriteIfChanged of a new file = %v, %v, want true, nil", changed, err)
+	}
+
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filePath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := WriteIfChanged(filePath, "a=1\n"); err != nil || changed {
+		t.Fatalf("WriteIfChanged with same content = %v, %v, want false, nil", changed, err)
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("unchanged file: mtime = %v, want %v", info.ModTime(), mtime)
+	}
+
+	if changed, err := WriteIfChanged(filePath, "a=1"); err != nil || !changed {
+		t.Fatalf("WriteIfChanged with a prefix of the content = %v, %v, want true, nil", changed, err)
+	}
+	if got := readTestFile(t, filePath); got != "a=1" {
+		t.Errorf("content = %q, want %q", got, "a=1")
+	}
+	if info, err = os.Stat(filePath); err != nil {
+		t.Fatal(err)
+	}
+	if info.ModTime().Equal(mtime) {
+		t.Errorf("changed file kept its mtime %v", mtime)
+	}
+}
+
+func TestCopyHash(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath, dstFilePath := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	data := strings.Repeat("hash me\n", 10000)
+	writeTestFile(t, srcFilePath, data)
+
+	digest, err := CopyHash(srcFilePath, dstFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(data))
+	if want := hex.EncodeToString(sum[:]); digest != want {
+		t.Errorf("CopyHash = %s, want %s", digest, want)
+	}
+	if got := readTestFile(t, dstFilePath); got != data {
+		t.Errorf("copied %d bytes, want %d", len(got), len(data))
+	}
+
+	if _, err := CopyHash(filepath.Join(dir, "missing"), dstFilePath); !os.IsNotExist(err) {
+		t.Errorf("CopyHash of missing file: got %v, want not exist", err)
+	}
+}
+
+func TestCopyStdio(t *testing.T) {
+	dir := t.TempDir()
+	data := strings.Repeat("piped line\n", 10000)
+
+	stdin, stdout := os.Stdin, os.Stdout
+	defer func() {
+		os.Stdin, os.Stdout = stdin, stdout
+	}()
+
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		inW.WriteString(data)
+		inW.Close()
+	}()
+	os.Stdin = inR
+	dstFilePath := filepath.Join(dir, "from-stdin")
+	n, err := CopyFromStdin(dstFilePath)
+	inR.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("CopyFromStdin copied %d bytes, want %d", n, len(data))
+	}
+	if got := readTestFile(t, dstFilePath); got != data {
+		t.Errorf("CopyFromStdin wrote %d bytes, want %d", len(got), len(data))
+	}
+
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := make(chan []byte)
+	go func() {
+		content, _ := ioutil.ReadAll(outR)
+		outR.Close()
+		output <- content
+	}()
+	os.Stdout = outW
+	n, err = CopyToStdout(dstFilePath)
+	outW.Close()
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("CopyToStdout copied %d bytes, want %d", n, len(data))
+	}
+	if got := <-output; string(got) != data {
+		t.Errorf("CopyToStdout wrote %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestMakeFIFO(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pipe")
+	if err := MakeFIFO(filePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("mode = %v, want a named pipe", info.Mode())
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("permissions = %v, want %v", info.Mode().Perm(), os.FileMode(0600))
+	}
+
+	err = MakeFIFO(filePath, 0600)
+	if !errors.Is(err, os.ErrExist) {
+		t.Errorf("MakeFIFO of an existing path: got %v, want os.ErrExist", err)
+	}
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != filePath {
+		t.Errorf("MakeFIFO error %v doesn't carry the path", err)
+	}
+}
+
+func TestCopyRangeInto(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath, dstFilePath := filepath.Join(dir, "patch"), filepath.Join(dir, "image")
+	writeTestFile(t, srcFilePath, "..PATCH..")
+	writeTestFile(t, dstFilePath, "0123456789")
+
+	if err := CopyRangeInto(srcFilePath, 2, 5, dstFilePath, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, dstFilePath); got != "012PATCH89" {
+		t.Errorf("patched content = %q, want %q", got, "012PATCH89")
+	}
+
+	if err := CopyRangeInto(srcFilePath, 2, 5, dstFilePath, 12); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, dstFilePath); got != "012PATCH89\x00\x00PATCH" {
+		t.Errorf("extended content = %q, want %q", got, "012PATCH89\x00\x00PATCH")
+	}
+
+	newFilePath := filepath.Join(dir, "new")
+	if err := CopyRangeInto(srcFilePath, 0, 2, newFilePath, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, newFilePath); got != ".." {
+		t.Errorf("created content = %q, want %q", got, "..")
+	}
+
+	err := CopyRangeInto(srcFilePath, 5, 10, dstFilePath, 0)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("CopyRangeInto past the end of the source: got %v, want io.ErrUnexpectedEOF", err)
+	}
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != srcFilePath {
+		t.Errorf("CopyRangeInto error %v doesn't carry the source path", err)
+	}
+}
+
+// flakyReadFS is a memFS whose file reads fail with err for the first failures opens.
+type flakyReadFS struct {
+	*memFS
+	err      error
+	failures int
+	opens    int
+}
+
+func (s *flakyReadFS) Open(name string) (File, error) {
+	file, err := s.memFS.Open(name)
+	s.opens++
+	if err != nil || s.opens > s.failures {
+		return file, err
+	}
+	return flakyReadFile{File: file, err: s.err}, nil
+}
+
+type flakyReadFile struct {
+	File
+	err error
+}
+
+func (f flakyReadFile) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: f.Name(), Err: f.err}
+}
+
+func TestReadRetry(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		err       error
+		failures  int
+		attempts  int
+		wantOpens int
+		wantErr   error
+	}{
+		{"recovers", syscall.ESTALE, 2, 3, 3, nil},
+		{"gives up", syscall.ESTALE, 2, 2, 2, syscall.ESTALE},
+		{"eio", syscall.EIO, 1, 3, 2, nil},
+		{"permanent", syscall.EACCES, 2, 3, 1, syscall.EACCES},
+		{"no attempts", syscall.ESTALE, 0, 0, 1, nil},
+	} {
+		flaky := &flakyReadFS{memFS: newMemFS(), err: tt.err, failures: tt.failures}
+		fs := NewFS(flaky)
+		if err := fs.Write("/data", "content"); err != nil {
+			t.Fatal(err)
+		}
+		content, err := fs.ReadRetry("/data", tt.attempts, time.Millisecond)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+			}
+		} else if err != nil || content != "content" {
+			t.Errorf("%s: ReadRetry = %q, %v; want %q", tt.name, content, err, "content")
+		}
+		if flaky.opens != tt.wantOpens {
+			t.Errorf("%s: %d reads attempted, want %d", tt.name, flaky.opens, tt.wantOpens)
+		}
+	}
+
+	flaky := &flakyReadFS{memFS: newMemFS()}
+	if _, err := NewFS(flaky).ReadRetry("/missing", 3, time.Millisecond); !os.IsNotExist(err) {
+		t.Errorf("ReadRetry of a missing file: got %v, want not exist", err)
+	}
+	if flaky.opens != 1 {
+		t.Errorf("ReadRetry of a missing file retried: %d opens, want 1", flaky.opens)
+	}
+}
+
+func TestRestoreFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, map[string]string{"backup/etc/app/app.conf": "restored"})
+	srcFilePath := filepath.Join(dir, "backup", "etc", "app", "app.conf")
+	srcModes := map[string]os.FileMode{"backup": 0705, "backup/etc": 0750, "backup/etc/app": 0711}
+	for relPath, mode := range srcModes {
+		if err := os.Chmod(filepath.Join(dir, filepath.FromSlash(relPath)), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(srcFilePath, 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(srcFilePath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	dstFilePath := filepath.Join(dir, "restore", "etc", "app", "app.conf")
+	if err := RestoreFile(srcFilePath, dstFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, dstFilePath); got != "restored" {
+		t.Errorf("content = %q, want %q", got, "restored")
+	}
+	info, err := os.Stat(dstFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("file mode = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("file mtime = %v, want %v", info.ModTime(), mtime)
+	}
+	for _, relPath := range []string{"restore", "restore/etc", "restore/etc/app"} {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(relPath)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := srcModes["backup"+strings.TrimPrefix(relPath, "restore")]
+		if info.Mode().Perm() != want {
+			t.Errorf("%s: mode = %v, want %v", relPath, info.Mode().Perm(), want)
+		}
+	}
+
+	dirInfo, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreFile(srcFilePath, filepath.Join(dir, "top.conf")); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode() != dirInfo.Mode() {
+		t.Errorf("existing parent mode changed: %v, %v", info, err)
+	}
+}
+
+func TestForEachEntry(t *testing.T) {
+	dir := t.TempDir()
+	const n = 2*readDirBatch + 10
+	for i := 0; i < n; i++ {
+		writeTestFile(t, filepath.Join(dir, fmt.Sprintf("f%04d", i)), "")
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	dirs := 0
+	err := ForEachEntry(dir, func(entry os.DirEntry) error {
+		if seen[entry.Name()] {
+			t.Errorf("entry %s seen twice", entry.Name())
+		}
+		seen[entry.Name()] = true
+		if entry.IsDir() {
+			dirs++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != n+1 || dirs != 1 {
+		t.Errorf("ForEachEntry saw %d entries with %d directories, want %d with 1", len(seen), dirs, n+1)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = ForEachEntry(dir, func(os.DirEntry) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("ForEachEntry with failing fn = %v after %d calls, want %v after 1", err, calls, stop)
+	}
+
+	fs := NewFS(newMemFS())
+	for _, name := range []string{"/d/a", "/d/b", "/d/c/x"} {
+		if err := fs.WriteMkdir(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var names []string
+	err = fs.ForEachEntry("/d", func(entry os.DirEntry) error {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.IsDir() != entry.IsDir() || entry.Type() != info.Mode().Type() {
+			t.Errorf("%s: entry and its Info disagree", entry.Name())
+		}
+		names = append(names, entry.Name())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ForEachEntry on memory FS = %v, want %v", names, want)
+	}
+}
+
+func TestCopyDeadline(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath := filepath.Join(dir, "src")
+	data := strings.Repeat("deadline\n", 50000)
+	writeTestFile(t, srcFilePath, data)
+
+	dstFilePath := filepath.Join(dir, "dst")
+	if err := CopyDeadline(srcFilePath, dstFilePath, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, dstFilePath); got != data {
+		t.Errorf("copied %d bytes, want %d", len(got), len(data))
+	}
+
+	past := time.Now().Add(-time.Second)
+	expiredFilePath := filepath.Join(dir, "expired")
+	if err := CopyDeadline(srcFilePath, expiredFilePath, past); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("CopyDeadline past deadline: got %v, want os.ErrDeadlineExceeded", err)
+	}
+	writeTestFile(t, dstFilePath, "previous")
+	if err := CopyDeadline(srcFilePath, dstFilePath, past); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("CopyDeadline past deadline over a file: got %v, want os.ErrDeadlineExceeded", err)
+	}
+	if Exists(expiredFilePath) {
+		t.Error("aborted copy left a partial file")
+	}
+	if got := readTestFile(t, dstFilePath); got != "previous" {
+		t.Errorf("aborted copy replaced the destination with %d bytes", len(got))
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("directory holds %d entries, want 2 without temporary files", len(entries))
+	}
+}
+
+func TestReadHeadTail(t *testing.T) {
+	dir := t.TempDir()
+	large := "HEAD" + strings.Repeat(".", 1<<20) + "TAIL"
+	for _, tt := range []struct {
+		name                 string
+		data                 string
+		headBytes, tailBytes int
+		head, tail           string
+	}{
+		{"large", large, 4, 4, "HEAD", "TAIL"},
+		{"tiny", "abc", 2, 4, "ab", "c"},
+		{"head covers all", "abc", 10, 4, "abc", ""},
+		{"exact", "abcd", 2, 2, "ab", "cd"},
+		{"empty", "", 4, 4, "", ""},
+		{"negative", "abc", -1, -1, "", ""},
+	} {
+		filePath := filepath.Join(dir, tt.name)
+		writeTestFile(t, filePath, tt.data)
+		head, tail, err := ReadHeadTail(filePath, tt.headBytes, tt.tailBytes)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(head) != tt.head || string(tail) != tt.tail {
+			t.Errorf("%s: ReadHeadTail = %q, %q; want %q, %q", tt.name, head, tail, tt.head, tt.tail)
+		}
+	}
+
+	if _, _, err := ReadHeadTail(filepath.Join(dir, "missing"), 1, 1); !os.IsNotExist(err) {
+		t.Errorf("ReadHeadTail of a missing file: got %v, want not exist", err)
+	}
+}
+
+func TestEnsureParentDir(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "a", "b", "c", "file.txt")
+	if err := EnsureParentDir(filePath); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Dir(filePath)); err != nil || !info.IsDir() {
+		t.Fatalf("parent not created: %v", err)
+	}
+	if Exists(filePath) {
+		t.Error("EnsureParentDir created the file itself")
+	}
+
+	writeTestFile(t, filePath, "kept")
+	if err := EnsureParentDir(filePath); err != nil {
+		t.Errorf("EnsureParentDir with an existing parent: %v", err)
+	}
+	if got := readTestFile(t, filePath); got != "kept" {
+		t.Errorf("existing file changed to %q", got)
+	}
+
+	if err := EnsureParentDir(filepath.Join(filePath, "below")); err == nil {
+		t.Error("EnsureParentDir under a regular file succeeded")
+	}
+}
+
+// shortReadFS is a memFS whose file reads return at most max bytes, like reads from a pipe or a network mount.
+type shortReadFS struct {
+	*memFS
+	max int
+}
+
+func (s shortReadFS) Open(name string) (File, error) {
+	file, err := s.memFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return shortReadFile{File: file, max: s.max}, nil
+}
+
+type shortReadFile struct {
+	File
+	max int
+}
+
+func (f shortReadFile) Read(p []byte) (int, error) {
+	if len(p) > f.max {
+		p = p[:f.max]
+	}
+	return f.File.Read(p)
+}
+
+func TestCountOccurrences(t *testing.T) {
+	const needle = "needle"
+	for _, fs := range []*FS{DefaultFS, NewFS(shortReadFS{memFS: newMemFS(), max: 4 << 10})} {
+		dir := "/"
+		if fs == DefaultFS {
+			dir = t.TempDir()
+		}
+		// Place the needle across each likely chunk boundary.
+		for _, boundary := range []int{4 << 10, 32 << 10, 32<<10 + len(needle) - 1, 64<<10 + len(needle) - 1} {
+			for shift := 1; shift < len(needle); shift++ {
+				data := strings.Repeat("x", boundary-shift) + needle + strings.Repeat("x", 100) + needle
+				filePath := filepath.Join(dir, fmt.Sprintf("data-%d-%d", boundary, shift))
+				if err := fs.Write(filePath, data); err != nil {
+					t.Fatal(err)
+				}
+				if n, err := fs.CountOccurrences(filePath, needle); err != nil || n != 2 {
+					t.Errorf("needle at %d-%d: CountOccurrences = %d, %v; want 2", boundary, shift, n, err)
+				}
+			}
+		}
+	}
+
+	filePath := filepath.Join(t.TempDir(), "small")
+	writeTestFile(t, filePath, "aaaaa\nab\nab")
+	for _, tt := range []struct {
+		substr string
+		want   int
+	}{
+		{"aa", 2},
+		{"b\na", 1},
+		{"ab", 2},
+		{"", 0},
+		{"missing", 0},
+	} {
+		if n, err := CountOccurrences(filePath, tt.substr); err != nil || n != tt.want {
+			t.Errorf("CountOccurrences(%q) = %d, %v; want %d", tt.substr, n, err, tt.want)
+		}
+	}
+}
+
+// shrinkingFS is a memFS whose files report a size larger than their content, as if truncated after Stat.
+type shrinkingFS struct {
+	*memFS
+}
+
+func (s shrinkingFS) Open(name string) (File, error) {
+	file, err := s.memFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return shrinkingFile{file}, nil
+}
+
+type shrinkingFile struct {
+	File
+}
+
+func (f shrinkingFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	mi := info.(memInfo)
+	mi.size += 100
+	return mi, nil
+}
+
+func TestReadHeadTailShrinking(t *testing.T) {
+	fs := NewFS(shrinkingFS{newMemFS()})
+	if err := fs.Write("/log", strings.Repeat("x", 1000)); err != nil {
+		t.Fatal(err)
+	}
+	head, tail, err := fs.ReadHeadTail("/log", 10, 10)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadHeadTail of a shrunk file = %q, %q, %v; want io.ErrUnexpectedEOF", head, tail, err)
+	}
+}
@@ -0,0 +1,113 @@
+package file
+
+import (
+	"io"
+	"os"
+)
+
+// FileSystem is the set of filesystem operations the helpers of this package are built on.
+// OsFileSystem implements it with the os package. Other implementations make it possible
+// to run the helpers against an in-memory filesystem, e.g. in unit tests.
+// Like the os package, implementations should report failures as *os.PathError,
+// so callers can match them with errors.Is and find the offending path with errors.As.
+type FileSystem interface {
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Create(name string) (File, error)
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Rename(oldName string, newName string) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	MkdirAll(name string, perm os.FileMode) error
+}
+
+// File is an open file of a FileSystem. *os.File implements it.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Readdir(n int) ([]os.FileInfo, error)
+	Readdirnames(n int) ([]string, error)
+	ReadDir(n int) ([]os.DirEntry, error)
+}
+
+// OsFileSystem is a FileSystem backed by the os package.
+type OsFileSystem struct{}
+
+// Open opens the named file for reading.
+func (OsFileSystem) Open(name string) (File, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// OpenFile opens the named file with specified flag and perm.
+func (OsFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	file, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Create creates or truncates the named file.
+func (OsFileSystem) Create(name string) (File, error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Stat returns file info of the named file, following symlinks.
+func (OsFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// Lstat returns file info of the named file, without following symlinks.
+func (OsFileSystem) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+// Rename renames a file or directory.
+func (OsFileSystem) Rename(oldName string, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+// Remove removes the named file or empty directory.
+func (OsFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// RemoveAll removes name and any children it contains.
+func (OsFileSystem) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+// MkdirAll creates a directory recursively.
+func (OsFileSystem) MkdirAll(name string, perm os.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+// FS provides the helpers of this package on top of a FileSystem.
+// The package-level functions are wrappers around DefaultFS.
+type FS struct {
+	fs FileSystem
+}
+
+// NewFS returns a FS operating on fs.
+func NewFS(fs FileSystem) *FS {
+	return &FS{fs: fs}
+}
+
+// DefaultFS is the FS used by the package-level functions, backed by OsFileSystem.
+var DefaultFS = NewFS(OsFileSystem{})
@@ -0,0 +1,374 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memFS is an in-memory FileSystem, showing FS doesn't depend on the os package.
+// Paths are cleaned, and "/" always exists. There are no symlinks, so Lstat is Stat.
+type memFS struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func newMemFS() *memFS {
+	return &memFS{nodes: map[string]*memNode{
+		"/": {mode: os.ModeDir | 0755, modTime: time.Now()},
+	}}
+}
+
+func (m *memFS) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (m *memFS) Create(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (m *memFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	node, ok := m.nodes[name]
+	switch {
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	case !ok:
+		if parent, ok := m.nodes[filepath.Dir(name)]; !ok || !parent.mode.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		node = &memNode{mode: perm.Perm(), modTime: time.Now()}
+		m.nodes[name] = node
+	case flag&os.O_TRUNC != 0:
+		node.data = nil
+		node.modTime = time.Now()
+	}
+
+	file := &memFile{fs: m, name: name, node: node, flag: flag}
+	if flag&os.O_APPEND != 0 {
+		file.offset = int64(len(node.data))
+	}
+	return file, nil
+}
+
+func (m *memFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return node.info(name), nil
+}
+
+func (m *memFS) Lstat(name string) (os.FileInfo, error) {
+	return m.Stat(name)
+}
+
+func (m *memFS) Rename(oldName string, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldName, newName = filepath.Clean(oldName), filepath.Clean(newName)
+	node, ok := m.nodes[oldName]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: os.ErrNotExist}
+	}
+	if _, ok := m.nodes[filepath.Dir(newName)]; !ok {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: os.ErrNotExist}
+	}
+	delete(m.nodes, oldName)
+	m.nodes[newName] = node
+	if node.mode.IsDir() {
+		for name, child := range m.nodes {
+			if strings.HasPrefix(name, oldName+"/") {
+				delete(m.nodes, name)
+				m.nodes[newName+strings.TrimPrefix(name, oldName)] = child
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	if _, ok := m.nodes[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	if len(m.children(name)) > 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+func (m *memFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	for nodeName := range m.nodes {
+		if nodeName == name || strings.HasPrefix(nodeName, name+"/") {
+			delete(m.nodes, nodeName)
+		}
+	}
+	return nil
+}
+
+func (m *memFS) MkdirAll(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	for dir := name; ; dir = filepath.Dir(dir) {
+		if node, ok := m.nodes[dir]; ok {
+			if !node.mode.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+			}
+			break
+		}
+		m.nodes[dir] = &memNode{mode: os.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+// children returns the sorted names of the entries directly in dir. m.mu must be held.
+func (m *memFS) children(dir string) []string {
+	prefix := dir + "/"
+	if dir == "/" {
+		prefix = "/"
+	}
+	var names []string
+	for name := range m.nodes {
+		if name != dir && strings.HasPrefix(name, prefix) && !strings.Contains(name[len(prefix):], "/") {
+			names = append(names, name[len(prefix):])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (n *memNode) info(name string) os.FileInfo {
+	return memInfo{name: filepath.Base(name), size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() os.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() interface{}   { return nil }
+
+// A memInfo is also the os.DirEntry returned by ReadDir.
+func (i memInfo) Type() os.FileMode          { return i.mode.Type() }
+func (i memInfo) Info() (os.FileInfo, error) { return i, nil }
+
+// memFile is an open file of a memFS. Content is shared with the node, so writes are seen right away.
+type memFile struct {
+	fs     *memFS
+	name   string
+	node   *memNode
+	flag   int
+	offset int64
+	dirPos int
+	closed bool
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: os.ErrClosed}
+	}
+	if f.node.mode.IsDir() {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: errors.New("is a directory")}
+	}
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: os.ErrClosed}
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: os.ErrPermission}
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	if end := f.offset + int64(len(p)); end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+	copy(f.node.data[f.offset:], p)
+	f.offset += int64(len(p))
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: errors.New("negative offset")}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return f.node.info(f.name), nil
+}
+
+func (f *memFile) Sync() error {
+	return nil
+}
+
+func (f *memFile) Readdir(n int) ([]os.FileInfo, error) {
+	names, err := f.Readdirnames(n)
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	infos := make([]os.FileInfo, 0, len(names))
+	for _, name := range names {
+		if node, ok := f.fs.nodes[filepath.Join(f.name, name)]; ok {
+			infos = append(infos, node.info(name))
+		}
+	}
+	return infos, err
+}
+
+func (f *memFile) ReadDir(n int) ([]os.DirEntry, error) {
+	infos, err := f.Readdir(n)
+	entries := make([]os.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = info.(memInfo)
+	}
+	return entries, err
+}
+
+func (f *memFile) Readdirnames(n int) ([]string, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if !f.node.mode.IsDir() {
+		return nil, &os.PathError{Op: "readdirent", Path: f.name, Err: errors.New("not a directory")}
+	}
+	names := f.fs.children(f.name)[f.dirPos:]
+	if n <= 0 {
+		f.dirPos += len(names)
+		return names, nil
+	}
+	if len(names) == 0 {
+		return nil, io.EOF
+	}
+	if len(names) > n {
+		names = names[:n]
+	}
+	f.dirPos += len(names)
+	return names, nil
+}
+
+func TestFSOnMemory(t *testing.T) {
+	mem := newMemFS()
+	fs := NewFS(mem)
+
+	if err := fs.WriteMkdir("/data/in/a.txt", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Copy("/data/in/a.txt", "/data/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := fs.Read("/data/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "hello" {
+		t.Errorf("Read after Copy = %q, want %q", content, "hello")
+	}
+
+	if err := fs.Write("/data/b.txt", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := fs.Read("/data/b.txt"); content != "hi" {
+		t.Errorf("Read after Write = %q, want %q", content, "hi")
+	}
+	if names, _ := fs.GetAllFiles("/data", ""); len(names) != 2 {
+		t.Errorf("GetAllFiles = %v, want the in directory and b.txt", names)
+	}
+
+	if err := fs.Copy("/data/missing", "/data/c.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Copy of missing file: got %v, want os.ErrNotExist", err)
+	}
+	if _, err := os.Stat("/data/b.txt"); err == nil {
+		t.Error("memory FS wrote to the real filesystem")
+	}
+}
@@ -1,5 +1,8 @@
 module github.com/bohehe/gofile
 
-go 1.15
+go 1.16
 
-require golang.org/x/sys v0.0.0-20220811171246-fbc7d0a398ab
+require (
+	golang.org/x/sys v0.0.0-20220811171246-fbc7d0a398ab
+	gopkg.in/yaml.v3 v3.0.1
+)
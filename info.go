@@ -0,0 +1,112 @@
+package file
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Info describes a file, as returned by Stat and Lstat.
+type Info struct {
+	Path      string
+	Name      string
+	Size      int64
+	Mode      os.FileMode
+	ModTime   time.Time
+	IsDir     bool
+	IsSymlink bool
+}
+
+func newInfo(filePath string, fi os.FileInfo) *Info {
+	return &Info{
+		Path:      filePath,
+		Name:      fi.Name(),
+		Size:      fi.Size(),
+		Mode:      fi.Mode(),
+		ModTime:   fi.ModTime(),
+		IsDir:     fi.IsDir(),
+		IsSymlink: fi.Mode()&os.ModeSymlink != 0,
+	}
+}
+
+// Stat returns Info of a file or directory, following symlinks.
+func Stat(filePath string) (*Info, error) {
+	return DefaultFS.Stat(filePath)
+}
+
+// Stat is like the package-level Stat but operates on f.
+func (f *FS) Stat(filePath string) (*Info, error) {
+	fi, err := f.fs.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return newInfo(filePath, fi), nil
+}
+
+// Lstat is like Stat, but describes a symlink itself rather than its target.
+func Lstat(filePath string) (*Info, error) {
+	return DefaultFS.Lstat(filePath)
+}
+
+// Lstat is like the package-level Lstat but operates on f.
+func (f *FS) Lstat(filePath string) (*Info, error) {
+	fi, err := f.fs.Lstat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return newInfo(filePath, fi), nil
+}
+
+// StatCache caches Info returned by Stat for a limited time, to avoid stating the same files over and over,
+// e.g. in watch loops. Failed stats are not cached. A StatCache is safe for concurrent use.
+type StatCache struct {
+	f   *FS
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]statCacheEntry
+}
+
+type statCacheEntry struct {
+	info    Info
+	expires time.Time
+}
+
+// NewStatCache returns a StatCache keeping stat results for ttl.
+func NewStatCache(ttl time.Duration) *StatCache {
+	return DefaultFS.NewStatCache(ttl)
+}
+
+// NewStatCache is like the package-level NewStatCache but operates on f.
+func (f *FS) NewStatCache(ttl time.Duration) *StatCache {
+	return &StatCache{f: f, ttl: ttl, entries: make(map[string]statCacheEntry)}
+}
+
+// Get returns Info of filePath like Stat, from the cache if it was stated less than the cache TTL ago.
+func (c *StatCache) Get(filePath string) (*Info, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[filePath]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		info := entry.info
+		return &info, nil
+	}
+
+	info, err := c.f.Stat(filePath)
+	if err != nil {
+		c.Invalidate(filePath)
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[filePath] = statCacheEntry{info: *info, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return info, nil
+}
+
+// Invalidate drops the cached Info of filePath, so the next Get stats it again.
+func (c *StatCache) Invalidate(filePath string) {
+	c.mu.Lock()
+	delete(c.entries, filePath)
+	c.mu.Unlock()
+}
@@ -0,0 +1,108 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLstat(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	writeTestFile(t, target, "some content")
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsSymlink || info.IsDir {
+		t.Errorf("Lstat(link): IsSymlink = %v, IsDir = %v; want true, false", info.IsSymlink, info.IsDir)
+	}
+	if info.Size != int64(len(target)) {
+		t.Errorf("Lstat(link).Size = %d, want the target path length %d", info.Size, len(target))
+	}
+	if info.Path != link || info.Name != "link" {
+		t.Errorf("Lstat(link): Path = %q, Name = %q", info.Path, info.Name)
+	}
+
+	info, err = Stat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.IsSymlink || info.Size != int64(len("some content")) {
+		t.Errorf("Stat(link): IsSymlink = %v, Size = %d; want the target", info.IsSymlink, info.Size)
+	}
+
+	if info, err := Lstat(dir); err != nil || !info.IsDir || info.IsSymlink {
+		t.Errorf("Lstat(dir) = %+v, %v", info, err)
+	}
+	if _, err := Lstat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("Lstat(missing): got %v, want not exist", err)
+	}
+}
+
+// statCountFS counts Stat calls.
+type statCountFS struct {
+	*memFS
+	stats int
+}
+
+func (s *statCountFS) Stat(name string) (os.FileInfo, error) {
+	s.stats++
+	return s.memFS.Stat(name)
+}
+
+func TestStatCache(t *testing.T) {
+	counting := &statCountFS{memFS: newMemFS()}
+	fs := NewFS(counting)
+	if err := fs.Write("/a", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := fs.NewStatCache(time.Hour)
+	get := func(wantStats int) {
+		t.Helper()
+		counting.stats = 0
+		info, err := cache.Get("/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != 3 {
+			t.Errorf("Get(/a).Size = %d, want 3", info.Size)
+		}
+		if counting.stats != wantStats {
+			t.Errorf("Get stated %d times, want %d", counting.stats, wantStats)
+		}
+	}
+	get(1)
+	get(0)
+	cache.Invalidate("/a")
+	get(1)
+	get(0)
+
+	// A returned Info can't alter the cached one.
+	info, _ := cache.Get("/a")
+	info.Size = 42
+	get(0)
+
+	counting.stats = 0
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get("/missing"); !os.IsNotExist(err) {
+			t.Errorf("Get(/missing): got %v, want not exist", err)
+		}
+	}
+	if counting.stats != 2 {
+		t.Errorf("failed stats were cached: %d stats for 2 Get calls", counting.stats)
+	}
+
+	cache = fs.NewStatCache(20 * time.Millisecond)
+	get(1)
+	get(0)
+	time.Sleep(30 * time.Millisecond)
+	get(1)
+}
@@ -0,0 +1,63 @@
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// AppendJSONLine encodes v as JSON on a single line and appends it to given file, followed by a newline.
+// It holds the lock of Lock meanwhile, so lines appended by concurrent writers never interleave.
+func AppendJSONLine(filePath string, v interface{}) (err error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := flock(lockPathOf(filePath), unix.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := lockFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	return AppendString(filePath, string(data)+"\n")
+}
+
+// ReadJSONLines reads given newline-delimited JSON file and calls fn with every line, skipping blank lines.
+// A line that isn't valid JSON is returned as a *ParseError.
+func ReadJSONLines(filePath string, fn func(raw json.RawMessage) error) error {
+	return DefaultFS.ReadJSONLines(filePath, fn)
+}
+
+// ReadJSONLines is like the package-level ReadJSONLines but operates on f.
+func (f *FS) ReadJSONLines(filePath string, fn func(raw json.RawMessage) error) (err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	lineNum := 0
+	return forEachLine(file, func(line string) error {
+		lineNum++
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		raw := json.RawMessage(line)
+		if !json.Valid(raw) {
+			return &ParseError{Path: filePath, Err: fmt.Errorf("line %d: invalid JSON", lineNum)}
+		}
+		return fn(raw)
+	})
+}
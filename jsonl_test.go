@@ -0,0 +1,88 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type jsonlRecord struct {
+	Writer  int    `json:"writer"`
+	Seq     int    `json:"seq"`
+	Payload string `json:"payload"`
+}
+
+func TestAppendJSONLineConcurrent(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "events.jsonl")
+	const writers, records = 8, 25
+	// Long lines make interleaving likely if appends weren't serialized.
+	payload := strings.Repeat("x", 16<<10)
+
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for seq := 0; seq < records; seq++ {
+				if err := AppendJSONLine(filePath, jsonlRecord{Writer: w, Seq: seq, Payload: payload}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	seen := make(map[[2]int]bool)
+	err := ReadJSONLines(filePath, func(raw json.RawMessage) error {
+		var r jsonlRecord
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return err
+		}
+		if r.Payload != payload || seen[[2]int{r.Writer, r.Seq}] {
+			t.Errorf("unexpected record %d/%d with %d bytes payload", r.Writer, r.Seq, len(r.Payload))
+		}
+		seen[[2]int{r.Writer, r.Seq}] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != writers*records {
+		t.Errorf("read %d records, want %d", len(seen), writers*records)
+	}
+}
+
+func TestReadJSONLines(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "good.jsonl")
+	writeTestFile(t, filePath, "{\"a\":1}\n\n  \n[1,2]\r\n\"text\"")
+	var got []string
+	err := ReadJSONLines(filePath, func(raw json.RawMessage) error {
+		got = append(got, strings.TrimSpace(string(raw)))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`{"a":1}`, "[1,2]", `"text"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadJSONLines = %q, want %q", got, want)
+	}
+
+	filePath = filepath.Join(dir, "bad.jsonl")
+	writeTestFile(t, filePath, "{\"a\":1}\n{\"a\":\n")
+	err = ReadJSONLines(filePath, func(json.RawMessage) error { return nil })
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("invalid line: got %v, want a *ParseError for line 2", err)
+	}
+}
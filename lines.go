@@ -0,0 +1,473 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// splitLines splits content into lines, each keeping its line ending.
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Uniq removes consecutive duplicate lines of given file, like the uniq command, and returns the number of lines removed.
+// The file is replaced atomically, and left untouched in case no line is removed.
+func Uniq(filePath string) (int, error) {
+	return DefaultFS.Uniq(filePath)
+}
+
+// Uniq is like the package-level Uniq but operates on f.
+func (f *FS) Uniq(filePath string) (int, error) {
+	return f.uniq(filePath, func(a, b string) bool { return a == b })
+}
+
+// UniqFold is like Uniq, but compares lines case-insensitively. The first line of a run of duplicates is kept.
+func UniqFold(filePath string) (int, error) {
+	return DefaultFS.UniqFold(filePath)
+}
+
+// UniqFold is like the package-level UniqFold but operates on f.
+func (f *FS) UniqFold(filePath string) (int, error) {
+	return f.uniq(filePath, strings.EqualFold)
+}
+
+func (f *FS) uniq(filePath string, equal func(a, b string) bool) (int, error) {
+	content, err := f.Read(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	var sb strings.Builder
+	removed := 0
+	prev, hasPrev := "", false
+	for _, line := range splitLines(content) {
+		text := trimLineEnding(line)
+		if hasPrev && equal(text, prev) {
+			removed++
+			continue
+		}
+		sb.WriteString(line)
+		prev, hasPrev = text, true
+	}
+
+	if removed == 0 {
+		return 0, nil
+	}
+	if err = f.writeAtomicString(filePath, sb.String()); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// SortLines sorts lines of given file lexically, in descending order if desc is true.
+// The whole file is sorted in memory, then replaced atomically.
+func SortLines(filePath string, desc bool) error {
+	return DefaultFS.SortLines(filePath, desc)
+}
+
+// SortLines is like the package-level SortLines but operates on f.
+func (f *FS) SortLines(filePath string, desc bool) error {
+	return f.sortLines(filePath, func(texts []string) {
+		sort.SliceStable(texts, func(i, j int) bool {
+			if desc {
+				return texts[i] > texts[j]
+			}
+			return texts[i] < texts[j]
+		})
+	})
+}
+
+// SortLinesNumeric is like SortLines, but sorts lines by their numeric value, e.g. "-2" < "10".
+// Lines that aren't numbers, NaN included, are ordered lexically and placed before all numbers,
+// or after them when desc is true.
+func SortLinesNumeric(filePath string, desc bool) error {
+	return DefaultFS.SortLinesNumeric(filePath, desc)
+}
+
+// SortLinesNumeric is like the package-level SortLinesNumeric but operates on f.
+func (f *FS) SortLinesNumeric(filePath string, desc bool) error {
+	type numericLine struct {
+		text  string
+		num   float64
+		isNum bool
+	}
+	return f.sortLines(filePath, func(texts []string) {
+		lines := make([]numericLine, len(texts))
+		for i, text := range texts {
+			num, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			lines[i] = numericLine{text: text, num: num, isNum: err == nil && !math.IsNaN(num)}
+		}
+		sort.SliceStable(lines, func(i, j int) bool {
+			a, b := lines[i], lines[j]
+			if desc {
+				a, b = b, a
+			}
+			switch {
+			case !a.isNum && !b.isNum:
+				return a.text < b.text
+			case !a.isNum || !b.isNum:
+				return !a.isNum
+			default:
+				return a.num < b.num
+			}
+		})
+		for i, line := range lines {
+			texts[i] = line.text
+		}
+	})
+}
+
+// sortLines replaces given file with its lines, without line endings, reordered by sortTexts.
+func (f *FS) sortLines(filePath string, sortTexts func(texts []string)) error {
+	content, err := f.Read(filePath)
+	if err != nil {
+		return err
+	}
+
+	lines := splitLines(content)
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = trimLineEnding(line)
+	}
+	sortTexts(texts)
+
+	lineEnding := "\n"
+	if strings.Contains(content, "\r\n") {
+		lineEnding = "\r\n"
+	}
+	sorted := strings.Join(texts, lineEnding)
+	if strings.HasSuffix(content, "\n") {
+		sorted += lineEnding
+	}
+	return f.writeAtomicString(filePath, sorted)
+}
+
+// MergeFiles concatenates srcFilePaths in order into dstFilePath, which is replaced atomically,
+// and returns the number of bytes written.
+func MergeFiles(dstFilePath string, srcFilePaths ...string) (int64, error) {
+	return DefaultFS.MergeFiles(dstFilePath, srcFilePaths...)
+}
+
+// MergeFiles is like the package-level MergeFiles but operates on f.
+func (f *FS) MergeFiles(dstFilePath string, srcFilePaths ...string) (int64, error) {
+	return f.mergeFiles(dstFilePath, srcFilePaths, false)
+}
+
+// MergeFilesNewline is like MergeFiles, but adds a "\n" after every source not ending with one,
+// so the last line of a source doesn't run into the first line of the next.
+func MergeFilesNewline(dstFilePath string, srcFilePaths ...string) (int64, error) {
+	return DefaultFS.MergeFilesNewline(dstFilePath, srcFilePaths...)
+}
+
+// MergeFilesNewline is like the package-level MergeFilesNewline but operates on f.
+func (f *FS) MergeFilesNewline(dstFilePath string, srcFilePaths ...string) (int64, error) {
+	return f.mergeFiles(dstFilePath, srcFilePaths, true)
+}
+
+func (f *FS) mergeFiles(dstFilePath string, srcFilePaths []string, newline bool) (n int64, err error) {
+	err = f.writeAtomic(dstFilePath, func(w io.Writer) error {
+		tw := &trackingWriter{w: w}
+		for i, srcFilePath := range srcFilePaths {
+			if _, err := f.copyTo(tw, srcFilePath); err != nil {
+				return err
+			}
+			if newline && i < len(srcFilePaths)-1 && tw.n > 0 && tw.last != '\n' {
+				if _, err := tw.Write([]byte{'\n'}); err != nil {
+					return err
+				}
+			}
+		}
+		n = tw.n
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// trackingWriter counts bytes written through it and remembers the last one.
+type trackingWriter struct {
+	w    io.Writer
+	n    int64
+	last byte
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if n > 0 {
+		t.n += int64(n)
+		t.last = p[n-1]
+	}
+	return n, err
+}
+
+// ForEachFixedWidth reads given file as fixed-width records and calls fn with the fields of every record,
+// each field being the next widths[i] bytes, trimmed of surrounding spaces.
+// Records are sum(widths) bytes long, and may be separated by a line ending.
+// A final record shorter than that is returned as a *ParseError.
+func ForEachFixedWidth(filePath string, widths []int, fn func(fields []string) error) error {
+	return DefaultFS.ForEachFixedWidth(filePath, widths, fn)
+}
+
+// ForEachFixedWidth is like the package-level ForEachFixedWidth but operates on f.
+func (f *FS) ForEachFixedWidth(filePath string, widths []int, fn func(fields []string) error) (err error) {
+	recordLen := 0
+	for _, width := range widths {
+		if width <= 0 {
+			return fmt.Errorf("invalid field width %d", width)
+		}
+		recordLen += width
+	}
+
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	br := bufio.NewReader(file)
+	record := make([]byte, recordLen)
+	for recordNum := 1; ; recordNum++ {
+		n, err := io.ReadFull(br, record)
+		if err == io.EOF {
+			return nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return &ParseError{Path: filePath, Err: fmt.Errorf("record %d: short record of %d bytes, want %d", recordNum, n, recordLen)}
+		}
+		if err != nil {
+			return err
+		}
+
+		fields := make([]string, len(widths))
+		offset := 0
+		for i, width := range widths {
+			fields[i] = strings.TrimSpace(string(record[offset : offset+width]))
+			offset += width
+		}
+		if err = fn(fields); err != nil {
+			return err
+		}
+
+		// Skip a line ending between records.
+		if next, _ := br.Peek(2); bytes.HasPrefix(next, []byte("\r\n")) {
+			br.Discard(2)
+		} else if len(next) > 0 && next[0] == '\n' {
+			br.Discard(1)
+		}
+	}
+}
+
+// SplitByLines splits given file into chunks of at most linesPerFile lines each,
+// written next to it as "filePath.00001", "filePath.00002" and so on. It returns the paths of created chunks.
+func SplitByLines(filePath string, linesPerFile int) ([]string, error) {
+	return DefaultFS.SplitByLines(filePath, linesPerFile)
+}
+
+// SplitByLines is like the package-level SplitByLines but operates on f.
+func (f *FS) SplitByLines(filePath string, linesPerFile int) ([]string, error) {
+	return f.splitByLines(filePath, linesPerFile, false)
+}
+
+// SplitByLinesHeader is like SplitByLines, but treats the first line of the file as a header, e.g. of a CSV file,
+// and repeats it at the start of every chunk. The header doesn't count in linesPerFile.
+func SplitByLinesHeader(filePath string, linesPerFile int) ([]string, error) {
+	return DefaultFS.SplitByLinesHeader(filePath, linesPerFile)
+}
+
+// SplitByLinesHeader is like the package-level SplitByLinesHeader but operates on f.
+func (f *FS) SplitByLinesHeader(filePath string, linesPerFile int) ([]string, error) {
+	return f.splitByLines(filePath, linesPerFile, true)
+}
+
+func (f *FS) splitByLines(filePath string, linesPerFile int, header bool) (chunkPaths []string, err error) {
+	if linesPerFile <= 0 {
+		return nil, fmt.Errorf("invalid lines per file %d", linesPerFile)
+	}
+
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	var (
+		chunk      File
+		chunkW     *bufio.Writer
+		chunkLines int
+		headerLine string
+	)
+	closeChunk := func() error {
+		if chunk == nil {
+			return nil
+		}
+		err := chunkW.Flush()
+		if closeErr := chunk.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		chunk = nil
+		return err
+	}
+	defer func() {
+		if closeErr := closeChunk(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	br := bufio.NewReader(file)
+	for lineNum := 0; ; lineNum++ {
+		line, readErr := br.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return chunkPaths, readErr
+		}
+		if line == "" {
+			return chunkPaths, nil
+		}
+		if header && lineNum == 0 {
+			headerLine = line
+			if !strings.HasSuffix(headerLine, "\n") {
+				headerLine += "\n"
+			}
+			continue
+		}
+
+		if chunk == nil || chunkLines == linesPerFile {
+			if err = closeChunk(); err != nil {
+				return chunkPaths, err
+			}
+			chunkPath := fmt.Sprintf("%s.%05d", filePath, len(chunkPaths)+1)
+			if chunk, err = f.fs.Create(chunkPath); err != nil {
+				return chunkPaths, err
+			}
+			chunkPaths = append(chunkPaths, chunkPath)
+			chunkW, chunkLines = bufio.NewWriter(chunk), 0
+			if _, err = chunkW.WriteString(headerLine); err != nil {
+				return chunkPaths, err
+			}
+		}
+		if _, err = chunkW.WriteString(line); err != nil {
+			return chunkPaths, err
+		}
+		chunkLines++
+
+		if readErr == io.EOF {
+			return chunkPaths, nil
+		}
+	}
+}
+
+// LineStats compares two versions of a file line by line, like diff, and returns how many lines
+// were added and removed going from oldFilePath to newFilePath. A modified line counts as one removed and one added.
+// Both files are held in memory, and the comparison takes time proportional to the product of their line counts
+// once common leading and trailing lines are skipped.
+func LineStats(oldFilePath string, newFilePath string) (added int, removed int, err error) {
+	return DefaultFS.LineStats(oldFilePath, newFilePath)
+}
+
+// LineStats is like the package-level LineStats but operates on f.
+func (f *FS) LineStats(oldFilePath string, newFilePath string) (added int, removed int, err error) {
+	oldContent, err := f.Read(oldFilePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	newContent, err := f.Read(newFilePath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	oldLines, newLines := splitLines(oldContent), splitLines(newContent)
+	for i := range oldLines {
+		oldLines[i] = trimLineEnding(oldLines[i])
+	}
+	for i := range newLines {
+		newLines[i] = trimLineEnding(newLines[i])
+	}
+
+	common := lcsLen(oldLines, newLines)
+	return len(newLines) - common, len(oldLines) - common, nil
+}
+
+// lcsLen returns the length of the longest common subsequence of a and b.
+func lcsLen(a []string, b []string) int {
+	common := 0
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b, common = a[1:], b[1:], common+1
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b, common = a[:len(a)-1], b[:len(b)-1], common+1
+	}
+
+	// Only the previous row of the table is needed to compute the next one.
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return common + prev[len(b)]
+}
+
+// CopyTransform copies file srcFilePath to dstFilePath line by line, passing each line, without its line ending,
+// through transform. Lines for which transform returns false are dropped, others are written as returned,
+// each followed by "\n". The destination is written atomically, and the source is streamed, not held in memory.
+func CopyTransform(srcFilePath string, dstFilePath string, transform func(line string) (string, bool)) error {
+	return DefaultFS.CopyTransform(srcFilePath, dstFilePath, transform)
+}
+
+// CopyTransform is like the package-level CopyTransform but operates on f.
+func (f *FS) CopyTransform(srcFilePath string, dstFilePath string, transform func(line string) (string, bool)) (err error) {
+	srcFile, err := f.fs.Open(srcFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := srcFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	return f.writeAtomic(dstFilePath, func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		err := forEachLine(srcFile, func(line string) error {
+			line, keep := transform(line)
+			if !keep {
+				return nil
+			}
+			_, err := bw.WriteString(line + "\n")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+}
@@ -0,0 +1,286 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUniq(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name    string
+		data    string
+		fold    bool
+		removed int
+		want    string
+	}{
+		{"consecutive", "a\na\na\nb\nb\nc\n", false, 3, "a\nb\nc\n"},
+		{"non-consecutive", "a\nb\na\nb\n", false, 0, "a\nb\na\nb\n"},
+		{"empty", "", false, 0, ""},
+		{"line endings differ", "a\r\na\nb", false, 1, "a\r\nb"},
+		{"case differs", "Error\nERROR\nerror\nok\n", false, 0, "Error\nERROR\nerror\nok\n"},
+		{"case folded", "Error\nERROR\nerror\nok\n", true, 2, "Error\nok\n"},
+	} {
+		filePath := filepath.Join(dir, tt.name)
+		writeTestFile(t, filePath, tt.data)
+
+		uniq := Uniq
+		if tt.fold {
+			uniq = UniqFold
+		}
+		removed, err := uniq(filePath)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if removed != tt.removed {
+			t.Errorf("%s: removed %d lines, want %d", tt.name, removed, tt.removed)
+		}
+		if got := readTestFile(t, filePath); got != tt.want {
+			t.Errorf("%s: content = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSortLines(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name    string
+		data    string
+		numeric bool
+		desc    bool
+		want    string
+	}{
+		{"ascending", "pear\napple\nfig\n", false, false, "apple\nfig\npear\n"},
+		{"descending", "pear\napple\nfig\n", false, true, "pear\nfig\napple\n"},
+		{"no trailing newline", "b\r\na\r\nc", false, false, "a\r\nb\r\nc"},
+		{"lexical numbers", "10\n-2\n9\n", false, false, "-2\n10\n9\n"},
+		{"numeric", "10\n-2\n9\n-10\n0.5\n", true, false, "-10\n-2\n0.5\n9\n10\n"},
+		{"numeric descending", "10\n-2\n9\n-10\n0.5\n", true, true, "10\n9\n0.5\n-2\n-10\n"},
+		{"numeric with text", "3\nx\n1\na\n", true, false, "a\nx\n1\n3\n"},
+		{"numeric with text descending", "3\nx\n1\na\n", true, true, "3\n1\nx\na\n"},
+		{"numeric with NaN", "3\nNaN\n1\n2\nNaN\n0\n", true, false, "NaN\nNaN\n0\n1\n2\n3\n"},
+		{"numeric with NaN descending", "3\nnan\n1\n-Inf\n", true, true, "3\n1\n-Inf\nnan\n"},
+	} {
+		filePath := filepath.Join(dir, tt.name)
+		writeTestFile(t, filePath, tt.data)
+
+		sortLines := SortLines
+		if tt.numeric {
+			sortLines = SortLinesNumeric
+		}
+		if err := sortLines(filePath, tt.desc); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := readTestFile(t, filePath); got != tt.want {
+			t.Errorf("%s: content = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePaths := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")}
+	writeTestFile(t, srcFilePaths[0], "one\n")
+	writeTestFile(t, srcFilePaths[1], "two")
+	writeTestFile(t, srcFilePaths[2], "three")
+
+	for _, tt := range []struct {
+		name    string
+		newline bool
+		want    string
+	}{
+		{"merged", false, "one\ntwothree"},
+		{"merged-newline", true, "one\ntwo\nthree"},
+	} {
+		dstFilePath := filepath.Join(dir, tt.name)
+		mergeFiles := MergeFiles
+		if tt.newline {
+			mergeFiles = MergeFilesNewline
+		}
+		n, err := mergeFiles(dstFilePath, srcFilePaths...)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if n != int64(len(tt.want)) {
+			t.Errorf("%s: wrote %d bytes, want %d", tt.name, n, len(tt.want))
+		}
+		if got := readTestFile(t, dstFilePath); got != tt.want {
+			t.Errorf("%s: content = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	dstFilePath := filepath.Join(dir, "failed")
+	if _, err := MergeFiles(dstFilePath, srcFilePaths[0], filepath.Join(dir, "missing")); err == nil {
+		t.Error("MergeFiles with a missing source succeeded")
+	}
+	if Exists(dstFilePath) {
+		t.Error("failed MergeFiles left its destination behind")
+	}
+}
+
+func TestForEachFixedWidth(t *testing.T) {
+	dir := t.TempDir()
+	widths := []int{4, 6, 3}
+	for _, tt := range []struct {
+		name string
+		data string
+		want [][]string
+	}{
+		{"separated", "0001Alice 030\r\n0002Bob   041\n0003  Carl102\n", [][]string{{"0001", "Alice", "030"}, {"0002", "Bob", "041"}, {"0003", "Carl", "102"}}},
+		{"contiguous", "0001Alice 0300002Bob   041", [][]string{{"0001", "Alice", "030"}, {"0002", "Bob", "041"}}},
+		{"empty", "", nil},
+	} {
+		filePath := filepath.Join(dir, tt.name)
+		writeTestFile(t, filePath, tt.data)
+		var got [][]string
+		err := ForEachFixedWidth(filePath, widths, func(fields []string) error {
+			got = append(got, fields)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: records = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	filePath := filepath.Join(dir, "short")
+	writeTestFile(t, filePath, "0001Alice 030\n0002Bob")
+	records := 0
+	err := ForEachFixedWidth(filePath, widths, func(fields []string) error {
+		records++
+		return nil
+	})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Path != filePath {
+		t.Errorf("short final record: got %v, want a *ParseError for %s", err, filePath)
+	}
+	if records != 1 {
+		t.Errorf("short final record: %d records before the error, want 1", records)
+	}
+
+	if err := ForEachFixedWidth(filePath, []int{4, 0}, func([]string) error { return nil }); err == nil {
+		t.Error("ForEachFixedWidth with a zero width succeeded")
+	}
+}
+
+func TestSplitByLines(t *testing.T) {
+	dir := t.TempDir()
+	var data string
+	for i := 1; i <= 10; i++ {
+		data += fmt.Sprintf("line %d\n", i)
+	}
+	filePath := filepath.Join(dir, "data.txt")
+	writeTestFile(t, filePath, data)
+
+	chunkPaths, err := SplitByLines(filePath, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPaths := []string{filePath + ".00001", filePath + ".00002", filePath + ".00003", filePath + ".00004"}
+	if !reflect.DeepEqual(chunkPaths, wantPaths) {
+		t.Fatalf("SplitByLines = %q, want %q", chunkPaths, wantPaths)
+	}
+	var joined string
+	for i, chunkPath := range chunkPaths {
+		chunk := readTestFile(t, chunkPath)
+		wantLines := 3
+		if i == len(chunkPaths)-1 {
+			wantLines = 1
+		}
+		if n := strings.Count(chunk, "\n"); n != wantLines {
+			t.Errorf("%s: %d lines, want %d", chunkPath, n, wantLines)
+		}
+		joined += chunk
+	}
+	if joined != data {
+		t.Errorf("chunks joined = %q, want %q", joined, data)
+	}
+
+	csvPath := filepath.Join(dir, "data.csv")
+	writeTestFile(t, csvPath, "id,name\n1,a\n2,b\n3,c")
+	chunkPaths, err = SplitByLinesHeader(csvPath, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunkPaths) != 2 {
+		t.Fatalf("SplitByLinesHeader = %q, want 2 chunks", chunkPaths)
+	}
+	for i, want := range []string{"id,name\n1,a\n2,b\n", "id,name\n3,c"} {
+		if got := readTestFile(t, chunkPaths[i]); got != want {
+			t.Errorf("%s = %q, want %q", chunkPaths[i], got, want)
+		}
+	}
+
+	if _, err := SplitByLines(filePath, 0); err == nil {
+		t.Error("SplitByLines with 0 lines per file succeeded")
+	}
+}
+
+func TestLineStats(t *testing.T) {
+	dir := t.TempDir()
+	old := "a\nb\nc\nd\n"
+	oldFilePath := filepath.Join(dir, "old")
+	writeTestFile(t, oldFilePath, old)
+	for _, tt := range []struct {
+		name           string
+		data           string
+		added, removed int
+	}{
+		{"identical", old, 0, 0},
+		{"inserted", "a\nb\nnew\nc\nd\n", 1, 0},
+		{"deleted", "a\nc\nd\n", 0, 1},
+		{"modified", "a\nB\nc\nd\n", 1, 1},
+		{"moved", "b\nc\nd\na\n", 1, 1},
+		{"line endings", "a\r\nb\r\nc\r\nd", 0, 0},
+		{"emptied", "", 0, 4},
+	} {
+		newFilePath := filepath.Join(dir, tt.name)
+		writeTestFile(t, newFilePath, tt.data)
+		added, removed, err := LineStats(oldFilePath, newFilePath)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if added != tt.added || removed != tt.removed {
+			t.Errorf("%s: LineStats = +%d -%d, want +%d -%d", tt.name, added, removed, tt.added, tt.removed)
+		}
+	}
+
+	if _, _, err := LineStats(oldFilePath, filepath.Join(dir, "missing")); err == nil {
+		t.Error("LineStats with a missing file succeeded")
+	}
+}
+
+func TestCopyTransform(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath, dstFilePath := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	writeTestFile(t, srcFilePath, "alpha\r\n\r\nbeta\n   \ngamma")
+
+	err := CopyTransform(srcFilePath, dstFilePath, func(line string) (string, bool) {
+		if strings.TrimSpace(line) == "" {
+			return "", false
+		}
+		return strings.ToUpper(line), true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readTestFile(t, dstFilePath), "ALPHA\nBETA\nGAMMA\n"; got != want {
+		t.Errorf("transformed content = %q, want %q", got, want)
+	}
+
+	failedFilePath := filepath.Join(dir, "failed")
+	err = CopyTransform(filepath.Join(dir, "missing"), failedFilePath, func(line string) (string, bool) { return line, true })
+	if !os.IsNotExist(err) {
+		t.Errorf("CopyTransform of a missing file: got %v, want not exist", err)
+	}
+	if Exists(failedFilePath) {
+		t.Error("failed CopyTransform created its destination")
+	}
+}
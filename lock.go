@@ -0,0 +1,197 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockPathOf returns the path of the lock file guarding filePath.
+// Updates replace filePath atomically by renaming, which would drop a lock held on filePath itself,
+// so the lock is taken on a sibling file that is never replaced.
+func lockPathOf(filePath string) string {
+	return filePath + ".lock"
+}
+
+// flock opens or creates lockPath and applies an advisory lock of given kind (unix.LOCK_SH or unix.LOCK_EX,
+// optionally with unix.LOCK_NB) on it. The lock is released by closing the returned file.
+func flock(lockPath string, how int) (*os.File, error) {
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = unix.Flock(int(file.Fd()), how)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, &os.PathError{Op: "flock", Path: lockPath, Err: err}
+	}
+	return file, nil
+}
+
+// ErrLocked is returned by TryLockDir, wrapped in a *os.PathError, when the lock is held elsewhere.
+var ErrLocked = errors.New("locked by another process")
+
+// FileLock is an advisory lock held on a lock file, released with Unlock.
+type FileLock struct {
+	file *os.File
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	return l.file.Close()
+}
+
+// Lock takes an exclusive lock on given file, blocking until it is available, e.g. around writing it.
+// The lock is shared with Edit, EditJSON, IncrementCounter and ReadLocked.
+// It is held on filePath with ".lock" appended, which is created if needed and left in place afterwards.
+func Lock(filePath string) (*FileLock, error) {
+	file, err := flock(lockPathOf(filePath), unix.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLock{file: file}, nil
+}
+
+// ReadLocked reads whole content string of a file like Read, holding a shared lock on it meanwhile,
+// so it never sees a write made under Lock half done. Several ReadLocked can run at the same time.
+func ReadLocked(filePath string) (content string, err error) {
+	lockFile, err := flock(lockPathOf(filePath), unix.LOCK_SH)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := lockFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	return Read(filePath)
+}
+
+// LockDir takes an exclusive lock on a directory, e.g. to make sure a single process works on it,
+// blocking until the lock is available. The lock is held on a ".lock" file inside dirPath,
+// which is created if needed and left in place afterwards.
+func LockDir(dirPath string) (*FileLock, error) {
+	file, err := flock(filepath.Join(dirPath, ".lock"), unix.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLock{file: file}, nil
+}
+
+// TryLockDir is like LockDir, but fails immediately with an error wrapping ErrLocked
+// in case the lock is already held.
+func TryLockDir(dirPath string) (*FileLock, error) {
+	lockPath := filepath.Join(dirPath, ".lock")
+	file, err := flock(lockPath, unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return nil, &os.PathError{Op: "flock", Path: lockPath, Err: ErrLocked}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &FileLock{file: file}, nil
+}
+
+// IncrementCounter increments the integer stored in given file and returns the new value.
+// A missing file counts as 0. The update is atomic and serialized across goroutines and processes
+// by an exclusive lock on filePath with ".lock" appended, which is left in place afterwards.
+func IncrementCounter(filePath string) (value int64, err error) {
+	lockFile, err := flock(lockPathOf(filePath), unix.LOCK_EX)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := lockFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	content, err := Read(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if content = strings.TrimSpace(content); content != "" {
+		if value, err = strconv.ParseInt(content, 10, 64); err != nil {
+			return 0, &ParseError{Path: filePath, Err: err}
+		}
+	}
+
+	value++
+	if err = DefaultFS.writeAtomicString(filePath, strconv.FormatInt(value, 10)); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// Edit replaces content of given file with what fn returns for its current content.
+// A missing file is edited as empty content. The update is atomic and serialized like IncrementCounter,
+// and the file is not rewritten in case fn returns the content unchanged or an error.
+func Edit(filePath string, fn func(old string) (string, error)) (err error) {
+	lockFile, err := flock(lockPathOf(filePath), unix.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := lockFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	old, err := Read(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	content, err := fn(old)
+	if err != nil || content == old {
+		return err
+	}
+	return DefaultFS.writeAtomicString(filePath, content)
+}
+
+// EditJSON decodes given JSON file into v, calls fn to modify v, then encodes v back into the file.
+// A missing file leaves v untouched before calling fn, so v should hold the zero value wanted in that case.
+// The update is atomic and serialized like Edit, and the file is not rewritten in case fn returns an error.
+// An error decoding the content is returned as a *ParseError.
+func EditJSON(filePath string, v interface{}, fn func() error) (err error) {
+	lockFile, err := flock(lockPathOf(filePath), unix.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := lockFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	content, err := Read(filePath)
+	switch {
+	case err == nil:
+		if err = json.Unmarshal([]byte(content), v); err != nil {
+			return &ParseError{Path: filePath, Err: err}
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	if err = fn(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return DefaultFS.writeAtomicString(filePath, string(data)+"\n")
+}
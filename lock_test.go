@@ -0,0 +1,270 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrementCounterConcurrent(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "counter")
+	const n = 50
+
+	values := make(chan int64, n)
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := IncrementCounter(filePath)
+			if err != nil {
+				errs <- err
+				return
+			}
+			values <- value
+		}()
+	}
+	wg.Wait()
+	close(values)
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	seen := make(map[int64]bool)
+	for value := range values {
+		if seen[value] {
+			t.Errorf("value %d returned twice", value)
+		}
+		seen[value] = true
+	}
+	for value := int64(1); value <= n; value++ {
+		if !seen[value] {
+			t.Errorf("value %d skipped", value)
+		}
+	}
+	if got := readTestFile(t, filePath); got != "50" {
+		t.Errorf("stored counter = %q, want %q", got, "50")
+	}
+}
+
+func TestEditConcurrent(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "lines")
+	writeTestFile(t, filePath, "header\n")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, line := range []string{"first\n", "second\n"} {
+		wg.Add(1)
+		go func(line string) {
+			defer wg.Done()
+			errs <- Edit(filePath, func(old string) (string, error) {
+				return old + line, nil
+			})
+		}(line)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := readTestFile(t, filePath)
+	if got != "header\nfirst\nsecond\n" && got != "header\nsecond\nfirst\n" {
+		t.Errorf("content = %q, want both appended lines", got)
+	}
+}
+
+func TestEditUnchanged(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "missing")
+	err := Edit(filePath, func(old string) (string, error) {
+		return old, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Exists(filePath) {
+		t.Error("Edit returning the content unchanged created the file")
+	}
+}
+
+func TestEditJSONConcurrent(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "state.json")
+	const n = 30
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var state struct {
+				Count int `json:"count"`
+			}
+			errs <- EditJSON(filePath, &state, func() error {
+				state.Count++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var state struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal([]byte(readTestFile(t, filePath)), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Count != n {
+		t.Errorf("count = %d, want %d", state.Count, n)
+	}
+}
+
+func TestLockDirConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	const n = 20
+
+	var mu sync.Mutex
+	holders, maxHolders := 0, 0
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := LockDir(dir)
+			if err != nil {
+				errs <- err
+				return
+			}
+			mu.Lock()
+			holders++
+			if holders > maxHolders {
+				maxHolders = holders
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			if err := lock.Unlock(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if maxHolders != 1 {
+		t.Errorf("lock held by %d goroutines at once, want 1", maxHolders)
+	}
+}
+
+func TestTryLockDir(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := LockDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := TryLockDir(dir); !errors.Is(err, ErrLocked) {
+		t.Errorf("TryLockDir of a locked directory: got %v, want ErrLocked", err)
+	}
+	var pathErr *os.PathError
+	if _, err := TryLockDir(dir); !errors.As(err, &pathErr) || pathErr.Path != filepath.Join(dir, ".lock") {
+		t.Errorf("TryLockDir error %v doesn't carry the lock path", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = TryLockDir(dir)
+	if err != nil {
+		t.Fatalf("TryLockDir of an unlocked directory: %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadLockedConcurrent(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "state")
+	const size = 64 << 10
+	writeTestFile(t, filePath, strings.Repeat("a", size))
+
+	// writeTorn rewrites the file in place in two halves, so an unlocked reader could see it half done.
+	writeTorn := func(c string) error {
+		lock, err := Lock(filePath)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+		file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if _, err := file.WriteString(strings.Repeat(c, size/2)); err != nil {
+			return err
+		}
+		time.Sleep(time.Millisecond)
+		_, err = file.WriteString(strings.Repeat(c, size/2))
+		return err
+	}
+
+	errs := make(chan error, 5)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			if err := writeTorn(string(rune('b' + i%2))); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	// Readers do a bounded number of reads with pauses, as overlapping shared locks would starve the writer.
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				content, err := ReadLocked(filePath)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if len(content) != size || strings.Count(content, content[:1]) != size {
+					errs <- fmt.Errorf("ReadLocked saw a torn write of %d bytes", len(content))
+					return
+				}
+				time.Sleep(100 * time.Microsecond)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,144 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestEntry describes a regular file in a Manifest.
+type ManifestEntry struct {
+	// RelPath is the slash-separated path relative to the manifest root.
+	RelPath string
+	Size    int64
+	// SHA256 is the hex-encoded SHA-256 digest of the content.
+	SHA256 string
+	Mode   os.FileMode
+}
+
+// Manifest describes every regular file under dirPath, sorted by RelPath,
+// so two trees with the same content always give equal manifests.
+func Manifest(dirPath string) ([]ManifestEntry, error) {
+	return DefaultFS.Manifest(dirPath)
+}
+
+// Manifest is like the package-level Manifest but operates on f.
+func (f *FS) Manifest(dirPath string) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	err := f.walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dirPath, filePath)
+		if err != nil {
+			return err
+		}
+		sum, err := f.sha256File(filePath)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ManifestEntry{
+			RelPath: filepath.ToSlash(relPath),
+			Size:    info.Size(),
+			SHA256:  sum,
+			Mode:    info.Mode(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].RelPath < entries[j].RelPath
+	})
+	return entries, nil
+}
+
+// DirChecksum returns a hex-encoded SHA-256 digest of the Manifest of dirPath, covering the relative path,
+// content digest and mode of every regular file. Trees with the same files, content and modes give
+// the same checksum, whatever order their directories are read in.
+func DirChecksum(dirPath string) (string, error) {
+	return DefaultFS.DirChecksum(dirPath)
+}
+
+// DirChecksum is like the package-level DirChecksum but operates on f.
+func (f *FS) DirChecksum(dirPath string) (string, error) {
+	entries, err := f.Manifest(dirPath)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, entry := range entries {
+		// Quote paths, so a name containing a newline can't forge another entry.
+		fmt.Fprintf(h, "%s %o %q\n", entry.SHA256, uint32(entry.Mode), entry.RelPath)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sha256File returns the hex-encoded SHA-256 digest of a file content.
+func (f *FS) sha256File(filePath string) (sum string, err error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CompareDirs compares regular files of two trees by relative path and content.
+// It returns the sorted relative paths of files only in dirPath1, only in dirPath2,
+// and in both but with different content.
+func CompareDirs(dirPath1 string, dirPath2 string) (onlyInA, onlyInB, different []string, err error) {
+	return DefaultFS.CompareDirs(dirPath1, dirPath2)
+}
+
+// CompareDirs is like the package-level CompareDirs but operates on f.
+func (f *FS) CompareDirs(dirPath1 string, dirPath2 string) (onlyInA, onlyInB, different []string, err error) {
+	entriesA, err := f.Manifest(dirPath1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	entriesB, err := f.Manifest(dirPath2)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Both manifests are sorted by RelPath, merge them.
+	i, j := 0, 0
+	for i < len(entriesA) || j < len(entriesB) {
+		switch {
+		case j == len(entriesB) || (i < len(entriesA) && entriesA[i].RelPath < entriesB[j].RelPath):
+			onlyInA = append(onlyInA, entriesA[i].RelPath)
+			i++
+		case i == len(entriesA) || entriesB[j].RelPath < entriesA[i].RelPath:
+			onlyInB = append(onlyInB, entriesB[j].RelPath)
+			j++
+		default:
+			if entriesA[i].SHA256 != entriesB[j].SHA256 {
+				different = append(different, entriesA[i].RelPath)
+			}
+			i++
+			j++
+		}
+	}
+	return onlyInA, onlyInB, different, nil
+}
@@ -0,0 +1,123 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	files := map[string]string{
+		"b.txt":     "hello",
+		"a/c.txt":   "",
+		"a/b/d.txt": "nested",
+	}
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeTestTree(t, dir1, files)
+	writeTestTree(t, dir2, files)
+
+	entries, err := Manifest(dir1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ManifestEntry{
+		{RelPath: "a/b/d.txt", Size: 6, SHA256: "233562de1a0288b139c4fa40b7d189f806e906eeb048517aeb67f34ac0e2faf1", Mode: 0644},
+		{RelPath: "a/c.txt", Size: 0, SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Mode: 0644},
+		{RelPath: "b.txt", Size: 5, SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", Mode: 0644},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Manifest = %+v, want %+v", entries, want)
+	}
+
+	entries2, err := Manifest(dir2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entries, entries2) {
+		t.Errorf("identical trees give different manifests:\n%+v\n%+v", entries, entries2)
+	}
+
+	if err := os.Chmod(filepath.Join(dir2, "b.txt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if entries2, _ = Manifest(dir2); reflect.DeepEqual(entries, entries2) {
+		t.Error("manifests equal after a mode change")
+	}
+}
+
+func TestCompareDirs(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	writeTestTree(t, dirA, map[string]string{
+		"same.txt":     "same",
+		"sub/edit.txt": "old",
+		"removed.txt":  "only in A",
+	})
+	writeTestTree(t, dirB, map[string]string{
+		"same.txt":     "same",
+		"sub/edit.txt": "new",
+		"sub/added":    "only in B",
+	})
+
+	onlyInA, onlyInB, different, err := CompareDirs(dirA, dirB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"removed.txt"}; !reflect.DeepEqual(onlyInA, want) {
+		t.Errorf("onlyInA = %v, want %v", onlyInA, want)
+	}
+	if want := []string{"sub/added"}; !reflect.DeepEqual(onlyInB, want) {
+		t.Errorf("onlyInB = %v, want %v", onlyInB, want)
+	}
+	if want := []string{"sub/edit.txt"}; !reflect.DeepEqual(different, want) {
+		t.Errorf("different = %v, want %v", different, want)
+	}
+}
+
+func TestDirChecksum(t *testing.T) {
+	files := map[string]string{"a.txt": "alpha", "sub/b.txt": "beta", "sub/deeper/c": "gamma"}
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeTestTree(t, dir1, files)
+	writeTestTree(t, dir2, files)
+	checksum := func(dir string) string {
+		t.Helper()
+		sum, err := DirChecksum(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+
+	sum1 := checksum(dir1)
+	if len(sum1) != 64 {
+		t.Errorf("DirChecksum = %q, want a hex SHA-256 digest", sum1)
+	}
+	if sum2 := checksum(dir2); sum2 != sum1 {
+		t.Errorf("duplicated trees have checksums %s and %s", sum1, sum2)
+	}
+
+	writeTestFile(t, filepath.Join(dir2, "sub", "b.txt"), "betA")
+	if checksum(dir2) == sum1 {
+		t.Error("checksum unchanged after changing a byte")
+	}
+	writeTestFile(t, filepath.Join(dir2, "sub", "b.txt"), "beta")
+	if checksum(dir2) != sum1 {
+		t.Error("checksum differs after restoring the content")
+	}
+
+	if err := os.Chmod(filepath.Join(dir2, "a.txt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if checksum(dir2) == sum1 {
+		t.Error("checksum unchanged after changing a mode")
+	}
+	if err := os.Chmod(filepath.Join(dir2, "a.txt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir2, "sub", "deeper", "c"), filepath.Join(dir2, "sub", "c")); err != nil {
+		t.Fatal(err)
+	}
+	if checksum(dir2) == sum1 {
+		t.Error("checksum unchanged after moving a file")
+	}
+}
@@ -0,0 +1,58 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// MMap maps a file into memory read-only, for fast random access to large files.
+// It returns the mapped content and a func to unmap it; the content must not be used after unmapping.
+// An empty file can not be mapped, so MMap returns an empty content with a no-op unmap func for it.
+func MMap(filePath string) (content []byte, unmap func() error, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := info.Size()
+	if size == 0 {
+		return []byte{}, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, &os.PathError{Op: "mmap", Path: filePath, Err: fmt.Errorf("file size %d overflows int", size)}
+	}
+
+	content, err = unix.Mmap(int(file.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "mmap", Path: filePath, Err: err}
+	}
+	return content, func() error {
+		return unix.Munmap(content)
+	}, nil
+}
+
+// ReadMMapString is like MMap, but returns the mapped content as a string, without copying it.
+// The string aliases the mapping: it, and any substring of it, must not be used after unmapping,
+// even when stored elsewhere, as accessing it then crashes the program.
+// Copy what has to outlive the mapping, e.g. with string([]byte(s)).
+func ReadMMapString(filePath string) (string, func() error, error) {
+	content, unmap, err := MMap(filePath)
+	if err != nil {
+		return "", nil, err
+	}
+	return *(*string)(unsafe.Pointer(&content)), unmap, nil
+}
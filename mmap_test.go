@@ -0,0 +1,69 @@
+package file
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMMap(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "index")
+	data := strings.Repeat("a", 10000) + "needle" + strings.Repeat("b", 10000)
+	writeTestFile(t, filePath, data)
+
+	content, unmap, err := MMap(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) != len(data) {
+		t.Errorf("mapped %d bytes, want %d", len(content), len(data))
+	}
+	if got := string(content[10000:10006]); got != "needle" {
+		t.Errorf("interior region = %q, want %q", got, "needle")
+	}
+	if err := unmap(); err != nil {
+		t.Errorf("unmap: %v", err)
+	}
+}
+
+func TestMMapEmptyFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "empty")
+	writeTestFile(t, filePath, "")
+
+	content, unmap, err := MMap(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) != 0 {
+		t.Errorf("mapped %d bytes of an empty file", len(content))
+	}
+	if err := unmap(); err != nil {
+		t.Errorf("unmap: %v", err)
+	}
+}
+
+func TestReadMMapString(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "index")
+	data := strings.Repeat("key=value\n", 5000)
+	writeTestFile(t, filePath, data)
+
+	content, unmap, err := ReadMMapString(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != data {
+		t.Errorf("ReadMMapString gave %d bytes, want %d", len(content), len(data))
+	}
+	// What outlives the mapping is copied first.
+	kept := string([]byte(content[:9]))
+	if err := unmap(); err != nil {
+		t.Errorf("unmap: %v", err)
+	}
+	if kept != "key=value" {
+		t.Errorf("copied prefix = %q, want %q", kept, "key=value")
+	}
+
+	if _, _, err := ReadMMapString(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("ReadMMapString of a missing file succeeded")
+	}
+}
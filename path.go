@@ -0,0 +1,247 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SplitPath splits filePath into its directory, its base name without extension, and its extension with the dot.
+// Only the last extension is split off ("a.tar.gz" gives "a.tar" and ".gz"),
+// and a leading dot is part of the name (".bashrc" has no extension).
+func SplitPath(filePath string) (dir string, name string, ext string) {
+	dir, base := filepath.Dir(filePath), filepath.Base(filePath)
+	ext = filepath.Ext(base)
+	if ext == base {
+		ext = ""
+	}
+	return dir, strings.TrimSuffix(base, ext), ext
+}
+
+// ChangeExt returns filePath with its extension replaced by newExt, which may be given with or without the dot.
+// An empty newExt removes the extension, and a path without extension gets newExt appended.
+func ChangeExt(filePath string, newExt string) string {
+	dir, name, _ := SplitPath(filePath)
+	if newExt != "" && !strings.HasPrefix(newExt, ".") {
+		newExt = "." + newExt
+	}
+	return filepath.Join(dir, name+newExt)
+}
+
+// UniquePath returns desiredPath in case nothing exists there yet, otherwise the first free path
+// numbered like "name (1).ext", "name (2).ext" and so on.
+func UniquePath(desiredPath string) (string, error) {
+	return DefaultFS.UniquePath(desiredPath)
+}
+
+// UniquePath is like the package-level UniquePath but operates on f.
+func (f *FS) UniquePath(desiredPath string) (string, error) {
+	dir, name, ext := SplitPath(desiredPath)
+	candidate := desiredPath
+	for i := 1; ; i++ {
+		if _, err := f.fs.Lstat(candidate); err != nil {
+			if os.IsNotExist(err) {
+				return candidate, nil
+			}
+			return "", err
+		}
+		candidate = filepath.Join(dir, name+" ("+strconv.Itoa(i)+")"+ext)
+	}
+}
+
+// RelPath returns the path of target relative to the directory base, e.g. for references between output files.
+// Both paths are cleaned and made absolute first, so relative and absolute paths can be mixed.
+// It fails in case the paths are on different volumes.
+func RelPath(base string, target string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	if baseVol, targetVol := filepath.VolumeName(absBase), filepath.VolumeName(absTarget); !strings.EqualFold(baseVol, targetVol) {
+		return "", fmt.Errorf("can't make %s relative to %s: different volumes %q and %q", target, base, targetVol, baseVol)
+	}
+	return filepath.Rel(absBase, absTarget)
+}
+
+// IsWithin reports whether target is base itself or inside it, e.g. to reject paths escaping a served directory.
+// Both paths are made absolute, cleaned, and have symlinks resolved, so neither ".." elements
+// nor a symlink pointing outside base can escape. target doesn't need to exist.
+func IsWithin(base string, target string) (bool, error) {
+	resolvedBase, err := resolvePath(base)
+	if err != nil {
+		return false, err
+	}
+	resolvedTarget, err := resolvePath(target)
+	if err != nil {
+		return false, err
+	}
+
+	rel, err := filepath.Rel(resolvedBase, resolvedTarget)
+	if err != nil {
+		return false, nil
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+// RealPath returns the canonical absolute path of an existing file, with all symlinks resolved,
+// so paths can be compared.
+func RealPath(filePath string) (string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(absPath)
+}
+
+// resolvePath returns the absolute path of filePath with symlinks resolved.
+// For a path that doesn't exist, symlinks are resolved in its longest existing parent,
+// and a dangling symlink is resolved to where it points.
+func resolvePath(filePath string) (string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := RealPath(absPath)
+	if err == nil {
+		return resolved, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	if info, lstatErr := os.Lstat(absPath); lstatErr == nil && info.Mode()&os.ModeSymlink != 0 {
+		// A dangling symlink, resolve where it points to.
+		linkTarget, err := os.Readlink(absPath)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(linkTarget) {
+			linkTarget = filepath.Join(filepath.Dir(absPath), linkTarget)
+		}
+		return resolvePath(linkTarget)
+	}
+	parent := filepath.Dir(absPath)
+	if parent == absPath {
+		return absPath, nil
+	}
+	resolvedParent, err := resolvePath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolvedParent, filepath.Base(absPath)), nil
+}
+
+// windowsReservedNames are device names Windows doesn't allow as file names, with or without extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// IsValidFilename checks if name can be used as a single file name: it must not be empty, "." or "..",
+// nor contain a path separator ('/' or '\\') or a NUL byte.
+func IsValidFilename(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// IsPortableFilename is like IsValidFilename, but also rejects names Windows doesn't allow:
+// reserved device names like CON or LPT1, the characters <>:"|?* and control characters,
+// and names ending with a dot or a space.
+func IsPortableFilename(name string) bool {
+	if !IsValidFilename(name) || strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return false
+	}
+	for _, r := range name {
+		if r < 0x20 || strings.ContainsRune(`<>:"|?*`, r) {
+			return false
+		}
+	}
+	stem := name
+	if i := strings.IndexByte(stem, '.'); i >= 0 {
+		stem = stem[:i]
+	}
+	return !windowsReservedNames[strings.ToUpper(stem)]
+}
+
+// SanitizeFilename turns name into a portable file name, replacing every character IsPortableFilename rejects with '_',
+// and appending '_' to reserved device names. An empty name, "." and ".." become "_".
+func SanitizeFilename(name string) string {
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		name = name[:len(name)-1] + "_"
+	}
+	if !IsPortableFilename(name) {
+		// A reserved device name.
+		stem, rest := name, ""
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			stem, rest = name[:i], name[i:]
+		}
+		name = stem + "_" + rest
+	}
+	return name
+}
+
+// CommonDir returns the longest directory containing all given file paths, after cleaning them,
+// e.g. "/a/b" for "/a/b/c.txt" and "/a/b/d/e.txt". For a single path, it is the directory of that path.
+// Relative paths sharing no other directory have "." in common, unless one of them leads out of it with "..".
+// It returns an empty string when the paths share no directory, like a relative and an absolute path.
+func CommonDir(filePaths ...string) string {
+	if len(filePaths) == 0 {
+		return ""
+	}
+
+	relative, escapes := true, false
+	var common []string
+	for i, filePath := range filePaths {
+		parts := splitDir(filepath.Dir(filepath.Clean(filePath)))
+		relative = relative && !filepath.IsAbs(filePath)
+		escapes = escapes || (len(parts) > 0 && parts[0] == "..")
+		if i == 0 {
+			common = parts
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+
+	switch {
+	case len(common) == 0 && relative && !escapes:
+		return "."
+	case len(common) == 0:
+		return ""
+	case len(common) == 1 && common[0] == "":
+		return string(filepath.Separator)
+	default:
+		return strings.Join(common, string(filepath.Separator))
+	}
+}
+
+// splitDir splits a cleaned directory path into its elements, the root of an absolute path being an empty element.
+// The current directory "." has no elements.
+func splitDir(dir string) []string {
+	switch dir {
+	case ".":
+		return nil
+	case string(filepath.Separator):
+		return []string{""}
+	}
+	return strings.Split(dir, string(filepath.Separator))
+}
@@ -0,0 +1,243 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitPath(t *testing.T) {
+	for _, tt := range []struct {
+		path, dir, name, ext string
+	}{
+		{"/data/report.txt", "/data", "report", ".txt"},
+		{"/data/archive.tar.gz", "/data", "archive.tar", ".gz"},
+		{"/data/v1.2.3.json", "/data", "v1.2.3", ".json"},
+		{"/data/Makefile", "/data", "Makefile", ""},
+		{"/home/.bashrc", "/home", ".bashrc", ""},
+		{"notes.md", ".", "notes", ".md"},
+		{"dir.d/file", "dir.d", "file", ""},
+	} {
+		dir, name, ext := SplitPath(tt.path)
+		if dir != tt.dir || name != tt.name || ext != tt.ext {
+			t.Errorf("SplitPath(%q) = %q, %q, %q; want %q, %q, %q", tt.path, dir, name, ext, tt.dir, tt.name, tt.ext)
+		}
+	}
+}
+
+func TestChangeExt(t *testing.T) {
+	for _, tt := range []struct {
+		path, ext, want string
+	}{
+		{"/data/foo.txt", ".json", "/data/foo.json"},
+		{"/data/foo.txt", "json", "/data/foo.json"},
+		{"/data/foo", ".json", "/data/foo.json"},
+		{"/data/foo.tar.gz", "xz", "/data/foo.tar.xz"},
+		{"/data/foo.txt", "", "/data/foo"},
+		{"/home/.bashrc", "bak", "/home/.bashrc.bak"},
+	} {
+		if got := ChangeExt(tt.path, tt.ext); got != tt.want {
+			t.Errorf("ChangeExt(%q, %q) = %q, want %q", tt.path, tt.ext, got, tt.want)
+		}
+	}
+}
+
+func TestUniquePath(t *testing.T) {
+	dir := t.TempDir()
+	desired := filepath.Join(dir, "file.txt")
+	if got, err := UniquePath(desired); err != nil || got != desired {
+		t.Errorf("UniquePath of free path = %q, %v; want %q", got, err, desired)
+	}
+
+	writeTestFile(t, desired, "")
+	writeTestFile(t, filepath.Join(dir, "file (1).txt"), "")
+	want := filepath.Join(dir, "file (2).txt")
+	if got, err := UniquePath(desired); err != nil || got != want {
+		t.Errorf("UniquePath = %q, %v; want %q", got, err, want)
+	}
+
+	noExt := filepath.Join(dir, "README")
+	writeTestFile(t, noExt, "")
+	if got, _ := UniquePath(noExt); got != noExt+" (1)" {
+		t.Errorf("UniquePath without extension = %q, want %q", got, noExt+" (1)")
+	}
+}
+
+func TestRelPath(t *testing.T) {
+	for _, tt := range []struct {
+		base, target, want string
+	}{
+		{"/a/b", "/a/b/c/d.txt", "c/d.txt"},
+		{"/a/b", "/a/x.txt", "../x.txt"},
+		{"/a/b/", "/a/b", "."},
+		{"/a/./b/../b", "/a/b/c", "c"},
+		{"/a/b", "/c/d", "../../c/d"},
+	} {
+		got, err := RelPath(tt.base, tt.target)
+		if err != nil {
+			t.Errorf("RelPath(%q, %q): %v", tt.base, tt.target, err)
+			continue
+		}
+		if got != filepath.FromSlash(tt.want) {
+			t.Errorf("RelPath(%q, %q) = %q, want %q", tt.base, tt.target, got, tt.want)
+		}
+	}
+
+	// Relative paths are taken from the working directory, like absolute ones below it.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := RelPath(wd, "sub/file"); err != nil || got != filepath.FromSlash("sub/file") {
+		t.Errorf("RelPath(wd, relative) = %q, %v; want %q", got, err, "sub/file")
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(base, "sub"), outside} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(base, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "missing"), filepath.Join(base, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "sub"), filepath.Join(base, "inner")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		target string
+		want   bool
+	}{
+		{filepath.Join(base, "sub", "file.txt"), true},
+		{base, true},
+		{filepath.Join(base, "sub", "..", "..", "outside", "file.txt"), false},
+		{filepath.Join(base, "..", "base-sibling"), false},
+		{filepath.Join(base, "escape", "file.txt"), false},
+		{filepath.Join(base, "dangling"), false},
+		{filepath.Join(base, "inner", "file.txt"), true},
+	} {
+		got, err := IsWithin(base, tt.target)
+		if err != nil {
+			t.Errorf("IsWithin(%s): %v", tt.target, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("IsWithin(%s) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestRealPath(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "target")
+	writeTestFile(t, target, "")
+	// link2 -> link1 -> target
+	if err := os.Symlink(target, filepath.Join(dir, "link1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("link1", filepath.Join(dir, "link2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := RealPath(filepath.Join(dir, "link2")); err != nil || got != target {
+		t.Errorf("RealPath(symlink chain) = %q, %v; want %q", got, err, target)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := RealPath("./link2"); err != nil || got != target {
+		t.Errorf("RealPath(relative) = %q, %v; want %q", got, err, target)
+	}
+
+	if _, err := RealPath(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("RealPath(missing): got %v, want a not-exist error", err)
+	}
+}
+
+func TestFilenameChecks(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		valid     bool
+		portable  bool
+		sanitized string
+	}{
+		{"report.txt", true, true, "report.txt"},
+		{".bashrc", true, true, ".bashrc"},
+		{"", false, false, "_"},
+		{".", false, false, "_"},
+		{"..", false, false, "_"},
+		{"a/b", false, false, "a_b"},
+		{`a\b`, false, false, "a_b"},
+		{"nul\x00byte", false, false, "nul_byte"},
+		{"what?", true, false, "what_"},
+		{`a<b>c:d"e|f*g`, true, false, "a_b_c_d_e_f_g"},
+		{"tab\there", true, false, "tab_here"},
+		{"trailing.", true, false, "trailing_"},
+		{"trailing ", true, false, "trailing_"},
+		{"CON", true, false, "CON_"},
+		{"con.txt", true, false, "con_.txt"},
+		{"lpt9.tar.gz", true, false, "lpt9_.tar.gz"},
+		{"console", true, true, "console"},
+		{"COM10", true, true, "COM10"},
+		{"grüße.txt", true, true, "grüße.txt"},
+	} {
+		if got := IsValidFilename(tt.name); got != tt.valid {
+			t.Errorf("IsValidFilename(%q) = %v, want %v", tt.name, got, tt.valid)
+		}
+		if got := IsPortableFilename(tt.name); got != tt.portable {
+			t.Errorf("IsPortableFilename(%q) = %v, want %v", tt.name, got, tt.portable)
+		}
+		got := SanitizeFilename(tt.name)
+		if got != tt.sanitized {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.name, got, tt.sanitized)
+		}
+		if !IsPortableFilename(got) {
+			t.Errorf("SanitizeFilename(%q) = %q, which isn't portable", tt.name, got)
+		}
+	}
+}
+
+func TestCommonDir(t *testing.T) {
+	for _, tt := range []struct {
+		paths []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"/a/b/c.txt"}, "/a/b"},
+		{[]string{"/a/b/c.txt", "/a/b/d/e.txt"}, "/a/b"},
+		{[]string{"/a/b/c.txt", "/a/bc/d.txt"}, "/a"},
+		{[]string{"/a/./b/../b/c.txt", "/a/b//d.txt"}, "/a/b"},
+		{[]string{"/x", "/y"}, "/"},
+		{[]string{"/a/x", "/b/y", "/a/z"}, "/"},
+		{[]string{"src/a.go", "src/sub/b.go"}, "src"},
+		{[]string{"a.go", "b.go"}, "."},
+		{[]string{"a.go", "src/b.go"}, "."},
+		{[]string{"a", "a/b"}, "."},
+		{[]string{"./src/a.go", "src/b/c.go"}, "src"},
+		{[]string{"../x/a.go", "../x/b.go"}, "../x"},
+		{[]string{"../a.go", "b.go"}, ""},
+		{[]string{"a/x", "b/y"}, "."},
+		{[]string{"/a/x", "a/x"}, ""},
+	} {
+		if got := CommonDir(tt.paths...); got != tt.want {
+			t.Errorf("CommonDir(%q) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
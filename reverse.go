@@ -0,0 +1,138 @@
+package file
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// reverseBlockSize is the size of blocks ReverseScanner reads from the end of a file.
+const reverseBlockSize = 4 << 10
+
+// ReverseScanner reads lines of a file from last to first, reading the file in blocks from its end,
+// so scanning the tail of a large file is cheap. Lines are split on "\n" and returned without line ending.
+// The scanner must be closed once done.
+type ReverseScanner struct {
+	file   File
+	offset int64
+	// buf holds the bytes read but not scanned yet. Blocks read while looking for the start of a line
+	// are kept in tail, the last of the file first, and joined once, so a long line is read in linear time.
+	buf  []byte
+	tail [][]byte
+	text string
+	done bool
+	err  error
+}
+
+// ReverseLineScanner returns a ReverseScanner over given file.
+func ReverseLineScanner(filePath string) (*ReverseScanner, error) {
+	return DefaultFS.ReverseLineScanner(filePath)
+}
+
+// ReverseLineScanner is like the package-level ReverseLineScanner but operates on f.
+func (f *FS) ReverseLineScanner(filePath string) (*ReverseScanner, error) {
+	file, err := f.fs.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	s := &ReverseScanner{file: file, offset: info.Size(), done: info.Size() == 0}
+	if s.offset > 0 {
+		// The line ending of the last line doesn't start a new line.
+		last := make([]byte, 1)
+		if err = readAtFull(file, last, s.offset-1); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if last[0] == '\n' {
+			s.offset--
+		}
+	}
+	return s, nil
+}
+
+// Scan advances to the previous line, which is then available through Text.
+// It returns false once the first line was passed or on error, see Err.
+func (s *ReverseScanner) Scan() bool {
+	for !s.done && s.err == nil {
+		if i := bytes.LastIndexByte(s.buf, '\n'); i >= 0 {
+			s.text = s.joinLine(s.buf[i+1:])
+			s.buf = s.buf[:i]
+			return true
+		}
+		if s.offset == 0 {
+			s.text = s.joinLine(s.buf)
+			s.buf = nil
+			s.done = true
+			return true
+		}
+
+		if len(s.buf) > 0 {
+			s.tail = append(s.tail, s.buf)
+		}
+		n := int64(reverseBlockSize)
+		if n > s.offset {
+			n = s.offset
+		}
+		block := make([]byte, n)
+		if err := readAtFull(s.file, block, s.offset-n); err != nil {
+			s.err = err
+			return false
+		}
+		s.offset -= n
+		s.buf = block
+	}
+	return false
+}
+
+// joinLine returns the line starting with head and continuing with the blocks kept in s.tail,
+// without its line ending, and empties s.tail.
+func (s *ReverseScanner) joinLine(head []byte) string {
+	if len(s.tail) == 0 {
+		return string(bytes.TrimSuffix(head, []byte{'\r'}))
+	}
+	size := len(head)
+	for _, block := range s.tail {
+		size += len(block)
+	}
+	line := make([]byte, 0, size)
+	line = append(line, head...)
+	for i := len(s.tail) - 1; i >= 0; i-- {
+		line = append(line, s.tail[i]...)
+	}
+	s.tail = s.tail[:0]
+	return string(bytes.TrimSuffix(line, []byte{'\r'}))
+}
+
+// readAtFull fills p from file at off, failing with io.ErrUnexpectedEOF, wrapped in a *os.PathError,
+// if the file has less than len(p) bytes there, e.g. because it was truncated meanwhile.
+func readAtFull(file File, p []byte, off int64) error {
+	n, err := file.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = &os.PathError{Op: "read", Path: file.Name(), Err: io.ErrUnexpectedEOF}
+	}
+	return err
+}
+
+// Text returns the line read by the last call to Scan.
+func (s *ReverseScanner) Text() string {
+	return s.text
+}
+
+// Err returns the error that stopped Scan, if any.
+func (s *ReverseScanner) Err() error {
+	return s.err
+}
+
+// Close closes the underlying file.
+func (s *ReverseScanner) Close() error {
+	return s.file.Close()
+}
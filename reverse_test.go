@@ -0,0 +1,84 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func scanReverse(t *testing.T, filePath string) []string {
+	t.Helper()
+	s, err := ReverseLineScanner(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var lines []string
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestReverseLineScanner(t *testing.T) {
+	dir := t.TempDir()
+	// Multi-byte runes straddling the 4KB block boundaries must come out intact.
+	long := strings.Repeat("ü日本", 3000)
+	for _, tt := range []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"trailing newline", "one\ntwo\r\nthree\n", []string{"three", "two", "one"}},
+		{"no trailing newline", "one\ntwo\nthree", []string{"three", "two", "one"}},
+		{"blank lines", "\na\n\nb\n", []string{"b", "", "a", ""}},
+		{"empty", "", nil},
+		{"single newline", "\n", []string{""}},
+		{"long lines", "first\n" + long + "\nmiddle\n" + long + "\nlast", []string{"last", long, "middle", long, "first"}},
+	} {
+		filePath := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-"))
+		writeTestFile(t, filePath, tt.data)
+		if got := scanReverse(t, filePath); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: lines = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReverseLineScannerTruncated(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "log")
+	writeTestFile(t, filePath, strings.Repeat("line\n", 5000))
+	s, err := ReverseLineScanner(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := os.Truncate(filePath, 100); err != nil {
+		t.Fatal(err)
+	}
+	for s.Scan() {
+		if s.Text() != "line" {
+			t.Fatalf("Scan returned %q from a truncated file", s.Text())
+		}
+	}
+	if err := s.Err(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestReverseLineScannerHugeLine(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "log")
+	huge := strings.Repeat("0123456789abcdef", 1<<18)
+	writeTestFile(t, filePath, "first\n"+huge+"\r\nlast\n")
+	if got := scanReverse(t, filePath); len(got) != 3 || got[0] != "last" || got[1] != huge || got[2] != "first" {
+		t.Errorf("scanned %d lines, want last, the 4MB line and first", len(got))
+	}
+}
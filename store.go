@@ -0,0 +1,45 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ContentPath returns where data is stored under baseDirPath in a content-addressed store:
+// its hex-encoded SHA-256 digest, sharded by the first two characters, like "baseDirPath/ab/cdef...".
+func ContentPath(baseDirPath string, data []byte) string {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	return filepath.Join(baseDirPath, digest[:2], digest[2:])
+}
+
+// StoreContent stores data in the content-addressed store under baseDirPath and returns its ContentPath.
+// Data already stored is not written again.
+func StoreContent(baseDirPath string, data []byte) (string, error) {
+	return DefaultFS.StoreContent(baseDirPath, data)
+}
+
+// StoreContent is like the package-level StoreContent but operates on f.
+func (f *FS) StoreContent(baseDirPath string, data []byte) (string, error) {
+	filePath := ContentPath(baseDirPath, data)
+	if _, err := f.fs.Stat(filePath); err == nil {
+		return filePath, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err := f.EnsureParentDir(filePath); err != nil {
+		return "", err
+	}
+	err := f.writeAtomic(filePath, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
@@ -0,0 +1,56 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreContent(t *testing.T) {
+	dir := t.TempDir()
+	filePath, err := StoreContent(dir, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, "2c", "f24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	if filePath != want {
+		t.Errorf("StoreContent = %q, want %q", filePath, want)
+	}
+	if got := readTestFile(t, filePath); got != "hello" {
+		t.Errorf("stored content = %q, want %q", got, "hello")
+	}
+
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filePath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	again, err := StoreContent(dir, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != filePath {
+		t.Errorf("second StoreContent = %q, want %q", again, filePath)
+	}
+	if info, err := os.Stat(filePath); err != nil || !info.ModTime().Equal(mtime) {
+		t.Errorf("second StoreContent rewrote the stored file")
+	}
+
+	var stored []string
+	err = filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			stored = append(stored, filePath)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 {
+		t.Errorf("store holds %v, want a single file", stored)
+	}
+
+	if other, err := StoreContent(dir, []byte("hello!")); err != nil || other == filePath {
+		t.Errorf("StoreContent of other data = %q, %v, want a different path", other, err)
+	}
+}
@@ -0,0 +1,52 @@
+package file
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Swap exchanges two files, so that each path then refers to what the other one referred to.
+// Where supported, as on Linux with most local filesystems, the exchange is atomic.
+// Otherwise it falls back to three renames through a temporary name next to path1,
+// during which path1 briefly doesn't exist.
+// Both paths must be on the same filesystem.
+func Swap(path1 string, path2 string) error {
+	err := renameExchange(path1, path2)
+	if err == nil || !isExchangeUnsupported(err) {
+		return err
+	}
+	return DefaultFS.swapRename(path1, path2)
+}
+
+// isExchangeUnsupported reports whether renameExchange failed because the system or filesystem lacks support.
+func isExchangeUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOSYS) ||
+		errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.ENOTSUP)
+}
+
+// swapRename exchanges two files with three renames, restoring path1 if a later rename fails.
+func (f *FS) swapRename(path1 string, path2 string) error {
+	// Reserve a unique temporary name, which the rename of path1 then replaces.
+	tmpFile, err := f.createTemp(path1, 0600)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+
+	if err = f.fs.Rename(path1, tmpPath); err != nil {
+		f.fs.Remove(tmpPath)
+		return err
+	}
+	if err = f.fs.Rename(path2, path1); err != nil {
+		f.fs.Rename(tmpPath, path1)
+		return err
+	}
+	if err = f.fs.Rename(tmpPath, path2); err != nil {
+		f.fs.Rename(path1, path2)
+		f.fs.Rename(tmpPath, path1)
+		return err
+	}
+	return nil
+}
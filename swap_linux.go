@@ -0,0 +1,15 @@
+package file
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// renameExchange atomically exchanges two paths with renameat2(2).
+func renameExchange(path1 string, path2 string) error {
+	if err := unix.Renameat2(unix.AT_FDCWD, path1, unix.AT_FDCWD, path2, unix.RENAME_EXCHANGE); err != nil {
+		return &os.LinkError{Op: "exchange", Old: path1, New: path2, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package file
+
+import (
+	"os"
+	"syscall"
+)
+
+// renameExchange reports that atomic exchange is not supported on this system.
+func renameExchange(path1 string, path2 string) error {
+	return &os.LinkError{Op: "exchange", Old: path1, New: path2, Err: syscall.ENOSYS}
+}
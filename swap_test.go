@@ -0,0 +1,68 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSwap(t *testing.T) {
+	dir := t.TempDir()
+	path1, path2 := filepath.Join(dir, "current"), filepath.Join(dir, "next")
+	writeTestFile(t, path1, "old config")
+	writeTestFile(t, path2, "new config")
+
+	if err := Swap(path1, path2); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, path1); got != "new config" {
+		t.Errorf("%s = %q, want %q", path1, got, "new config")
+	}
+	if got := readTestFile(t, path2); got != "old config" {
+		t.Errorf("%s = %q, want %q", path2, got, "old config")
+	}
+
+	missing := filepath.Join(dir, "missing")
+	if err := Swap(path1, missing); !os.IsNotExist(err) {
+		t.Errorf("Swap with a missing path: got %v, want not exist", err)
+	}
+	if got := readTestFile(t, path1); got != "new config" {
+		t.Errorf("failed Swap changed %s to %q", path1, got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("directory holds %d entries, want 2 without temporary files", len(entries))
+	}
+}
+
+func TestSwapRename(t *testing.T) {
+	mem := newMemFS()
+	fs := NewFS(mem)
+	if err := fs.Write("/a", "A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Write("/b", "B"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.swapRename("/a", "/b"); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"/a": "B", "/b": "A"} {
+		if got, err := fs.Read(name); err != nil || got != want {
+			t.Errorf("after swapRename, %s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	if err := fs.swapRename("/a", "/missing"); !os.IsNotExist(err) {
+		t.Errorf("swapRename with a missing path: got %v, want not exist", err)
+	}
+	if got, err := fs.Read("/a"); err != nil || got != "B" {
+		t.Errorf("failed swapRename left /a = %q, %v; want %q", got, err, "B")
+	}
+	mem.mu.Lock()
+	names := mem.children("/")
+	mem.mu.Unlock()
+	if len(names) != 2 {
+		t.Errorf("swapRename left %v, want only a and b", names)
+	}
+}
@@ -0,0 +1,113 @@
+package file
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// PollChange hashes given file every interval and calls onChange whenever its content differs from last time,
+// until ctx is done, then returns ctx.Err(). It works on any filesystem as it doesn't rely on inotify.
+// A missing file is not an error, but a state of its own: deleting the file and creating it again
+// are both reported as changes.
+func PollChange(ctx context.Context, filePath string, interval time.Duration, onChange func()) error {
+	return DefaultFS.PollChange(ctx, filePath, interval, onChange)
+}
+
+// PollChange is like the package-level PollChange but operates on f.
+func (f *FS) PollChange(ctx context.Context, filePath string, interval time.Duration, onChange func()) error {
+	return f.pollChange(ctx, filePath, interval, onChange, func(err error) error {
+		return err
+	})
+}
+
+// pollChange runs the loop of PollChange. Failures to hash the file are passed to onErr, which ends polling
+// by returning an error, or else keeps it going while the file is considered unchanged.
+func (f *FS) pollChange(ctx context.Context, filePath string, interval time.Duration, onChange func(), onErr func(err error) error) error {
+	last, err := f.pollDigest(filePath)
+	if err != nil {
+		if err = onErr(err); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		digest, err := f.pollDigest(filePath)
+		if err != nil {
+			if err = onErr(err); err != nil {
+				return err
+			}
+			continue
+		}
+		if digest != last {
+			last = digest
+			onChange()
+		}
+	}
+}
+
+// pollDigest returns the content digest of a file, or an empty string in case it doesn't exist.
+func (f *FS) pollDigest(filePath string) (string, error) {
+	digest, err := f.sha256File(filePath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return digest, err
+}
+
+// watchReloadInterval is how often WatchReload checks for changes. Tests shorten it.
+var watchReloadInterval = time.Second
+
+// WatchReload loads given file once by calling reload with its content, then calls reload again every time
+// the content changes, until ctx is done. It returns the error of the first load, or else ctx.Err().
+// Once the first load succeeded, failures to check, read or reload the file are logged with the standard logger
+// and otherwise ignored, so the caller keeps using the last content it reloaded successfully.
+// Changes are detected by checking the file every second, like PollChange.
+func WatchReload(ctx context.Context, filePath string, reload func(content string) error) error {
+	return DefaultFS.WatchReload(ctx, filePath, reload)
+}
+
+// WatchReload is like the package-level WatchReload but operates on f.
+func (f *FS) WatchReload(ctx context.Context, filePath string, reload func(content string) error) error {
+	return f.WatchReloadLog(ctx, filePath, reload, log.Printf)
+}
+
+// WatchReloadLog is like WatchReload, but logs failures with logf instead of the standard logger.
+func WatchReloadLog(ctx context.Context, filePath string, reload func(content string) error, logf func(format string, args ...interface{})) error {
+	return DefaultFS.WatchReloadLog(ctx, filePath, reload, logf)
+}
+
+// WatchReloadLog is like the package-level WatchReloadLog but operates on f.
+func (f *FS) WatchReloadLog(ctx context.Context, filePath string, reload func(content string) error, logf func(format string, args ...interface{})) error {
+	content, err := f.Read(filePath)
+	if err != nil {
+		return err
+	}
+	if err = reload(content); err != nil {
+		return err
+	}
+
+	onChange := func() {
+		content, err := f.Read(filePath)
+		if err != nil {
+			logf("file: keeping last loaded %s: %v", filePath, err)
+			return
+		}
+		if err = reload(content); err != nil {
+			logf("file: keeping last loaded %s: reload: %v", filePath, err)
+		}
+	}
+	return f.pollChange(ctx, filePath, watchReloadInterval, onChange, func(err error) error {
+		logf("file: keeping last loaded %s: %v", filePath, err)
+		return nil
+	})
+}
@@ -0,0 +1,159 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// waitChange waits for a value on changes, failing the test after a while.
+func waitChange(t *testing.T, changes <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no change reported after %s", what)
+	}
+}
+
+func TestPollChange(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "watched")
+	writeTestFile(t, filePath, "v1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{}, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- PollChange(ctx, filePath, 5*time.Millisecond, func() { changes <- struct{}{} })
+	}()
+	// Let PollChange record the initial digest.
+	time.Sleep(50 * time.Millisecond)
+
+	writeTestFile(t, filePath, "v2")
+	waitChange(t, changes, "modifying the file")
+	if err := os.Remove(filePath); err != nil {
+		t.Fatal(err)
+	}
+	waitChange(t, changes, "deleting the file")
+	writeTestFile(t, filePath, "v2")
+	waitChange(t, changes, "recreating the file")
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("PollChange returned %v, want context.Canceled", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("%d unexpected changes reported", len(changes))
+	}
+}
+
+// failingOpenFS is a memFS whose Open fails with EIO while fail is set.
+type failingOpenFS struct {
+	*memFS
+	fail int32
+}
+
+func (m *failingOpenFS) Open(name string) (File, error) {
+	if atomic.LoadInt32(&m.fail) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EIO}
+	}
+	return m.memFS.Open(name)
+}
+
+func TestWatchReloadLog(t *testing.T) {
+	defer func(interval time.Duration) { watchReloadInterval = interval }(watchReloadInterval)
+	watchReloadInterval = 5 * time.Millisecond
+
+	mem := &failingOpenFS{memFS: newMemFS()}
+	fs := NewFS(mem)
+	if err := fs.Write("/config", "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reloads := make(chan string, 10)
+	logs := make(chan string, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- fs.WatchReloadLog(ctx, "/config", func(content string) error {
+			reloads <- content
+			if content == "bad" {
+				return errors.New("invalid config")
+			}
+			return nil
+		}, func(format string, args ...interface{}) {
+			logs <- fmt.Sprintf(format, args...)
+		})
+	}()
+	waitReload := func(want string) {
+		t.Helper()
+		select {
+		case got := <-reloads:
+			if got != want {
+				t.Errorf("reloaded %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no reload of %q", want)
+		}
+	}
+	waitLog := func(want string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case msg := <-logs:
+				if strings.Contains(msg, want) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("nothing logged containing %q", want)
+			}
+		}
+	}
+
+	waitReload("v1")
+	if err := fs.Write("/config", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	waitReload("v2")
+
+	if err := fs.Write("/config", "bad"); err != nil {
+		t.Fatal(err)
+	}
+	waitReload("bad")
+	waitLog("invalid config")
+
+	atomic.StoreInt32(&mem.fail, 1)
+	waitLog("input/output error")
+	if err := fs.Write("/config", "v3"); err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&mem.fail, 0)
+	waitReload("v3")
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("WatchReloadLog returned %v, want context.Canceled", err)
+	}
+}
+
+func TestWatchReloadFirstLoadFails(t *testing.T) {
+	err := WatchReload(context.Background(), filepath.Join(t.TempDir(), "missing"), func(string) error { return nil })
+	if !os.IsNotExist(err) {
+		t.Errorf("WatchReload of a missing file: got %v, want not exist", err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "config")
+	writeTestFile(t, filePath, "bad")
+	loadErr := errors.New("invalid config")
+	if err := WatchReload(context.Background(), filePath, func(string) error { return loadErr }); err != loadErr {
+		t.Errorf("WatchReload with a failing first load: got %v, want %v", err, loadErr)
+	}
+}
@@ -0,0 +1,86 @@
+package file
+
+import (
+	"bytes"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// CopyXattrs copies extended attributes of srcFilePath, such as user.* attributes or SELinux labels, onto dstFilePath.
+// Filesystems without extended attribute support are not an error, nothing is copied then.
+func CopyXattrs(srcFilePath string, dstFilePath string) error {
+	names, err := listXattrs(srcFilePath)
+	if err != nil {
+		if isXattrUnsupported(err) {
+			return nil
+		}
+		return &os.PathError{Op: "listxattr", Path: srcFilePath, Err: err}
+	}
+
+	for _, name := range names {
+		value, err := getXattr(srcFilePath, name)
+		if err != nil {
+			return &os.PathError{Op: "getxattr", Path: srcFilePath, Err: err}
+		}
+		if err = unix.Setxattr(dstFilePath, name, value, 0); err != nil {
+			if isXattrUnsupported(err) {
+				return nil
+			}
+			return &os.PathError{Op: "setxattr", Path: dstFilePath, Err: err}
+		}
+	}
+	return nil
+}
+
+func isXattrUnsupported(err error) bool {
+	return err == unix.ENOTSUP || err == unix.EOPNOTSUPP
+}
+
+// listXattrs returns names of extended attributes of a file.
+func listXattrs(filePath string) ([]string, error) {
+	buf, err := readXattrBuf(func(dest []byte) (int, error) {
+		return unix.Listxattr(filePath, dest)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range bytes.Split(buf, []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+// getXattr returns value of an extended attribute of a file.
+func getXattr(filePath string, name string) ([]byte, error) {
+	return readXattrBuf(func(dest []byte) (int, error) {
+		return unix.Getxattr(filePath, name, dest)
+	})
+}
+
+// readXattrBuf calls read with a buffer large enough for the result,
+// retrying in case the attributes grow between sizing the buffer and reading.
+func readXattrBuf(read func(dest []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := read(nil)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := read(buf)
+		if err == unix.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
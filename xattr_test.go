@@ -0,0 +1,34 @@
+package file
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestCopyXattrs(t *testing.T) {
+	dir := t.TempDir()
+	srcFilePath, dstFilePath := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	writeTestFile(t, srcFilePath, "src")
+	writeTestFile(t, dstFilePath, "dst")
+
+	value := []byte("a value")
+	if err := unix.Setxattr(srcFilePath, "user.gofile.test", value, 0); err != nil {
+		if isXattrUnsupported(err) {
+			t.Skip("user extended attributes not supported here")
+		}
+		t.Fatal(err)
+	}
+
+	if err := CopyXattrs(srcFilePath, dstFilePath); err != nil {
+		t.Fatal(err)
+	}
+	got, err := getXattr(dstFilePath, "user.gofile.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(value) {
+		t.Errorf("copied xattr = %q, want %q", got, value)
+	}
+}